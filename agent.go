@@ -0,0 +1,32 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/ai-shift/opencode/opencodeconfig"
+)
+
+type Agent struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Mode        opencodeconfig.AgentMode `json:"mode"`
+	// BuiltIn is true for agents shipped with opencode, such as build and plan.
+	BuiltIn     bool                       `json:"builtIn"`
+	Model       *Model                     `json:"model,omitempty"`
+	Prompt      string                     `json:"prompt,omitempty"`
+	Temperature *float64                   `json:"temperature,omitempty"`
+	TopP        *float64                   `json:"topP,omitempty"`
+	Tools       map[string]bool            `json:"tools,omitempty"`
+	Permission  *opencodeconfig.Permission `json:"permission,omitempty"`
+}
+
+// ListAgents returns the built-in and configured agents.
+func (oc *OpenCode) ListAgents(ctx context.Context) ([]Agent, error) {
+	var agents []Agent
+	if err := oc.do(ctx, http.MethodGet, "/agent", nil, &agents); err != nil {
+		return nil, fmt.Errorf("failed to list agents: %w", err)
+	}
+	return agents, nil
+}
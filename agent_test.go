@@ -0,0 +1,42 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/ai-shift/opencode/opencodeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAgents(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/agent", r.URL.Path)
+		w.Write([]byte(`[
+			{"name":"build","mode":"primary","builtIn":true,"tools":{},"permission":{"edit":"allow","bash":{"*":"allow"},"webfetch":"allow"},"options":{}},
+			{"name":"review","description":"Reviews diffs","mode":"subagent","builtIn":false,"model":{"providerID":"anthropic","modelID":"claude-sonnet-4-5"},"temperature":0.1,"tools":{"write":false},"permission":{"edit":"deny","bash":"ask"},"options":{}}
+		]`))
+	})
+
+	agents, err := oc.ListAgents(context.Background())
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+	assert.True(t, agents[0].BuiltIn)
+	assert.Equal(t, opencodeconfig.AgentSubagent, agents[1].Mode)
+	assert.Equal(t, &Model{ProviderID: "anthropic", ModelID: "claude-sonnet-4-5"}, agents[1].Model)
+	assert.Equal(t, map[string]opencodeconfig.PermissionLevel{"*": opencodeconfig.Ask}, agents[1].Permission.Bash)
+}
+
+func TestSendMessageWithAgent(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req messageRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "plan", req.Agent)
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant"},"parts":[]}`))
+	})
+
+	_, err := oc.SendMessage(context.Background(), "ses_1", WithAgent("plan"), Text("outline the change"))
+	require.NoError(t, err)
+}
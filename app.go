@@ -0,0 +1,72 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+)
+
+type AppInfo struct {
+	Hostname string `json:"hostname"`
+	// Git reports whether the project is a git repository.
+	Git  bool     `json:"git"`
+	Path AppPaths `json:"path"`
+	Time AppTime  `json:"time"`
+}
+
+type AppPaths struct {
+	Config string `json:"config"`
+	Data   string `json:"data"`
+	Root   string `json:"root"`
+	Cwd    string `json:"cwd"`
+	State  string `json:"state"`
+}
+
+type AppTime struct {
+	// Initialized is zero until AppInit has run for the project.
+	Initialized int64 `json:"initialized,omitempty"`
+}
+
+// AppInfo returns the server's hostname, git status and paths, e.g. to check
+// that it serves the expected directory.
+func (oc *OpenCode) AppInfo(ctx context.Context) (*AppInfo, error) {
+	var info AppInfo
+	if err := oc.do(ctx, http.MethodGet, "/app", nil, &info); err != nil {
+		return nil, fmt.Errorf("failed to get app info: %w", err)
+	}
+	return &info, nil
+}
+
+// AppInit runs the server's first-time initialization for the project.
+func (oc *OpenCode) AppInit(ctx context.Context) error {
+	if err := oc.doPrompt(ctx, http.MethodPost, "/app/init", nil, nil); err != nil {
+		return fmt.Errorf("failed to initialize app: %w", err)
+	}
+	return nil
+}
+
+type Paths struct {
+	Home   string `json:"home,omitempty"`
+	Config string `json:"config"`
+	// Data holds session storage. Servers that do not report it get
+	// Config.DataDir/opencode when DataDir is set.
+	Data     string `json:"data,omitempty"`
+	State    string `json:"state"`
+	Worktree string `json:"worktree"`
+	// Directory is the request's directory within Worktree.
+	Directory string `json:"directory"`
+}
+
+// Paths returns where the server keeps its config, data and state, and the
+// worktree it operates on.
+func (oc *OpenCode) Paths(ctx context.Context) (*Paths, error) {
+	var paths Paths
+	if err := oc.do(ctx, http.MethodGet, "/path", nil, &paths); err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+	if paths.Data == "" && oc.config.DataDir != "" {
+		paths.Data = filepath.Join(oc.config.DataDir, "opencode")
+	}
+	return &paths, nil
+}
@@ -0,0 +1,54 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppInfoAndInit(t *testing.T) {
+	var initialized bool
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/app":
+			w.Write([]byte(`{"hostname":"build-1","git":true,"path":{"config":"/home/u/.config/opencode","data":"/home/u/.local/share/opencode/project/work","root":"/work","cwd":"/work/api","state":"/home/u/.local/state/opencode"},"time":{"initialized":1700000000000}}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/app/init":
+			initialized = true
+			w.Write([]byte("true"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	info, err := oc.AppInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "build-1", info.Hostname)
+	assert.True(t, info.Git)
+	assert.Equal(t, "/work/api", info.Path.Cwd)
+	assert.Equal(t, int64(1700000000000), info.Time.Initialized)
+
+	require.NoError(t, oc.AppInit(context.Background()))
+	assert.True(t, initialized)
+}
+
+func TestPaths(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/path", r.URL.Path)
+		w.Write([]byte(`{"home":"/home/u","state":"/home/u/.local/state/opencode","config":"/home/u/.config/opencode","worktree":"/work","directory":"/work/api"}`))
+	})
+	oc.config.DataDir = "/var/lib/agents"
+
+	paths, err := oc.Paths(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, &Paths{
+		Home:      "/home/u",
+		Config:    "/home/u/.config/opencode",
+		Data:      "/var/lib/agents/opencode",
+		State:     "/home/u/.local/state/opencode",
+		Worktree:  "/work",
+		Directory: "/work/api",
+	}, paths)
+}
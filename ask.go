@@ -0,0 +1,111 @@
+package opencode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+func (m *MessageWithParts) Text() string {
+	var sb strings.Builder
+	for _, part := range m.Parts {
+		if text, ok := part.(*TextPart); ok && !text.Synthetic {
+			sb.WriteString(text.Text)
+		}
+	}
+	return sb.String()
+}
+
+func (oc *OpenCode) Ask(ctx context.Context, sessionID, prompt string) (string, error) {
+	message, err := oc.SendMessage(ctx, sessionID, Text(prompt))
+	if err != nil {
+		return "", err
+	}
+	if message.Info.Error != nil {
+		return "", fmt.Errorf("assistant message %s failed: %w", message.Info.ID, message.Info.Error)
+	}
+	return message.Text(), nil
+}
+
+func (oc *OpenCode) StreamResponse(ctx context.Context, sessionID, prompt string, w io.Writer) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	roles := map[string]string{}
+	written := map[string]int{}
+	var writeErr error
+	write := func(part *TextPart, delta string) {
+		if writeErr != nil {
+			return
+		}
+		if delta == "" {
+			if len(part.Text) <= written[part.ID] {
+				return
+			}
+			delta = part.Text[written[part.ID]:]
+		}
+		if _, err := io.WriteString(w, delta); err != nil {
+			writeErr = fmt.Errorf("failed to write response: %w", err)
+			return
+		}
+		written[part.ID] += len(delta)
+	}
+
+	// The shared stream must be connected before sending, or the first
+	// deltas could be missed
+	sub := oc.SubscribeWith(streamCtx, SubscribeOptions{
+		Filter: EventFilter{SessionID: sessionID, Types: []string{EventMessageUpdated, EventMessagePartUpdated}},
+	})
+	select {
+	case <-sub.connected():
+	case _, ok := <-sub.C:
+		if !ok {
+			if oc.closeCtx.Err() != nil {
+				return ErrClosed
+			}
+			return errors.New("event stream ended before it connected")
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	streamDone := make(chan struct{})
+	go func() {
+		defer close(streamDone)
+		for event := range sub.C {
+			switch e := event.(type) {
+			case *MessageUpdatedEvent:
+				roles[e.Info.ID] = e.Info.Role
+			case *MessagePartUpdatedEvent:
+				part, ok := e.Part.(*TextPart)
+				if !ok || part.Synthetic || roles[part.MessageID] != RoleAssistant {
+					continue
+				}
+				write(part, e.Delta)
+			}
+		}
+	}()
+
+	message, err := oc.SendMessage(ctx, sessionID, Text(prompt))
+	cancel()
+	<-streamDone
+	if err != nil {
+		return err
+	}
+
+	// Deltas still in flight when the response arrived are written from the final message
+	for _, part := range message.Parts {
+		if text, ok := part.(*TextPart); ok && !text.Synthetic {
+			write(text, "")
+		}
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if message.Info.Error != nil {
+		return fmt.Errorf("assistant message %s failed: %w", message.Info.ID, message.Info.Error)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsk(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/session/ses_1/message", r.URL.Path)
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant","finish":"stop"},"parts":[
+			{"id":"prt_1","type":"step-start"},
+			{"id":"prt_2","type":"reasoning","text":"hmm"},
+			{"id":"prt_3","type":"text","text":"Hello, "},
+			{"id":"prt_4","type":"text","text":"world"},
+			{"id":"prt_5","type":"text","text":"ignored","synthetic":true}
+		]}`))
+	})
+
+	answer, err := oc.Ask(context.Background(), "ses_1", "hi")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world", answer)
+}
+
+func TestAskAssistantError(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant","error":{"name":"MessageAbortedError","data":{"message":"aborted"}}},"parts":[]}`))
+	})
+
+	_, err := oc.Ask(context.Background(), "ses_1", "hi")
+	assert.ErrorContains(t, err, "MessageAbortedError: aborted")
+	assert.ErrorIs(t, err, ErrAborted)
+}
+
+func TestStreamResponse(t *testing.T) {
+	events := make(chan string, 10)
+	var connections atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			connections.Add(1)
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case event := <-events:
+					fmt.Fprintf(w, "data: %s\n\n", event)
+					w.(http.Flusher).Flush()
+				}
+			}
+		case "/session/ses_1/message":
+			events <- `{"type":"message.updated","properties":{"info":{"id":"msg_1","sessionID":"ses_1","role":"user"}}}`
+			events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_1","sessionID":"ses_1","messageID":"msg_1","type":"text","text":"hi"}}}`
+			events <- `{"type":"message.updated","properties":{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant"}}}`
+			events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_2","sessionID":"ses_1","messageID":"msg_2","type":"text","text":"Hello"},"delta":"Hello"}}`
+			events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_2","sessionID":"ses_1","messageID":"msg_2","type":"text","text":"Hello, "},"delta":", "}}`
+			w.Write([]byte(`{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant"},"parts":[{"id":"prt_2","sessionID":"ses_1","messageID":"msg_2","type":"text","text":"Hello, world"}]}`))
+		}
+	})
+
+	var out strings.Builder
+	err := oc.StreamResponse(context.Background(), "ses_1", "hi", &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world", out.String())
+
+	// The response shares the event stream of other subscribers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	oc.Subscribe(ctx)
+	out.Reset()
+	assert.NoError(t, oc.StreamResponse(context.Background(), "ses_1", "hi", &out))
+	assert.Equal(t, "Hello, world", out.String())
+	assert.EqualValues(t, 2, connections.Load())
+}
+
+func TestStreamResponseStreamFails(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/event" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+	var out strings.Builder
+	assert.Error(t, oc.StreamResponse(context.Background(), "ses_1", "hi", &out))
+
+	require.NoError(t, oc.Close(context.Background()))
+	assert.ErrorIs(t, oc.StreamResponse(context.Background(), "ses_1", "hi", &out), ErrClosed)
+}
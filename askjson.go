@@ -0,0 +1,155 @@
+package opencode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// clientLogger returns the logger of an *OpenCode, and slog.Default() for
+// other Client implementations.
+func clientLogger(c Client) *slog.Logger {
+	if oc, ok := c.(*OpenCode); ok {
+		return oc.log
+	}
+	return slog.Default()
+}
+
+func AskJSON[T any](ctx context.Context, oc Client, sessionID, prompt string, schema any, maybeRetries ...int) (T, error) {
+	var result T
+	retries := 2
+	if len(maybeRetries) > 0 {
+		retries = maybeRetries[0]
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return result, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	var schemaDoc any
+	if err := json.Unmarshal(schemaJSON, &schemaDoc); err != nil {
+		return result, fmt.Errorf("failed to decode schema: %w", err)
+	}
+
+	message := fmt.Sprintf("%s\n\nRespond only with a single JSON value that conforms to this JSON schema, without any other text:\n%s", prompt, schemaJSON)
+	for attempt := 0; ; attempt++ {
+		answer, err := oc.Ask(ctx, sessionID, message)
+		if err != nil {
+			return result, err
+		}
+
+		err = decodeJSONAnswer(answer, schemaDoc, &result)
+		if err == nil {
+			return result, nil
+		}
+		if attempt >= retries {
+			return result, fmt.Errorf("invalid JSON answer after %d attempts: %w", attempt+1, err)
+		}
+		clientLogger(oc).Debug("Retrying malformed JSON answer", "session", sessionID, "attempt", attempt+1, "err", err)
+		message = fmt.Sprintf("Your previous answer was invalid: %s. Respond again with only the JSON value conforming to the schema.", err)
+	}
+}
+
+func decodeJSONAnswer(answer string, schema any, out any) error {
+	raw, err := extractJSON(answer)
+	if err != nil {
+		return err
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	if err := validateSchema(schema, value, "$"); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	return nil
+}
+
+func extractJSON(answer string) (json.RawMessage, error) {
+	start := strings.IndexAny(answer, "{[")
+	if start < 0 {
+		return nil, errors.New("no JSON value found in answer")
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(answer[start:])).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	return bytes.TrimSpace(raw), nil
+}
+
+// validateSchema checks the subset of JSON schema models reliably follow:
+// type, enum, required, properties and items.
+func validateSchema(schema, value any, path string) error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if typ, ok := s["type"].(string); ok && !matchesType(typ, value) {
+		return fmt.Errorf("%s: expected %s", path, typ)
+	}
+	if enum, ok := s["enum"].([]any); ok && !slices.ContainsFunc(enum, func(v any) bool { return fmt.Sprint(v) == fmt.Sprint(value) }) {
+		return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if required, ok := s["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := v[fmt.Sprint(name)]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		if properties, ok := s["properties"].(map[string]any); ok {
+			for name, prop := range properties {
+				if field, ok := v[name]; ok {
+					if err := validateSchema(prop, field, path+"."+name); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if err := validateSchema(s["items"], item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(typ string, value any) bool {
+	switch typ {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type weather struct {
+	City string  `json:"city"`
+	Temp float64 `json:"temp"`
+}
+
+var weatherSchema = map[string]any{
+	"type":     "object",
+	"required": []string{"city", "temp"},
+	"properties": map[string]any{
+		"city": map[string]any{"type": "string"},
+		"temp": map[string]any{"type": "number"},
+	},
+}
+
+func answerServer(t *testing.T, answers ...string) (*OpenCode, *[]string) {
+	var prompts []string
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var req messageRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		prompts = append(prompts, req.Parts[0].Text)
+		answer, _ := json.Marshal(answers[len(prompts)-1])
+		fmt.Fprintf(w, `{"info":{"id":"msg","role":"assistant"},"parts":[{"id":"prt","type":"text","text":%s}]}`, answer)
+	})
+	return oc, &prompts
+}
+
+func TestAskJSON(t *testing.T) {
+	oc, prompts := answerServer(t, "Sure!\n```json\n{\"city\": \"Paris\", \"temp\": 21.5}\n```")
+
+	result, err := AskJSON[weather](context.Background(), oc, "ses_1", "weather in Paris?", weatherSchema)
+	require.NoError(t, err)
+	assert.Equal(t, weather{City: "Paris", Temp: 21.5}, result)
+	assert.Contains(t, (*prompts)[0], `"required":["city","temp"]`)
+}
+
+func TestAskJSONRetriesInvalidAnswer(t *testing.T) {
+	oc, prompts := answerServer(t, `{"city": "Paris"}`, `{"city": "Paris", "temp": "warm"}`, `{"city": "Paris", "temp": 20}`)
+
+	result, err := AskJSON[weather](context.Background(), oc, "ses_1", "weather in Paris?", weatherSchema)
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, result.Temp)
+	require.Len(t, *prompts, 3)
+	assert.Contains(t, (*prompts)[1], `missing required property "temp"`)
+	assert.Contains(t, (*prompts)[2], "$.temp: expected number")
+}
+
+func TestAskJSONGivesUp(t *testing.T) {
+	oc, _ := answerServer(t, "no idea", "still no idea")
+
+	_, err := AskJSON[weather](context.Background(), oc, "ses_1", "weather?", weatherSchema, 1)
+	assert.ErrorContains(t, err, "invalid JSON answer after 2 attempts")
+}
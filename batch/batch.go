@@ -0,0 +1,151 @@
+// Package batch runs a list of prompts, each in a fresh session, with a
+// concurrency limit and reports their responses, token counts and costs.
+package batch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-shift/opencode"
+)
+
+// Prompt is one line of a JSONL prompt file.
+type Prompt struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+}
+
+// Result is the outcome of one prompt. Error is a string so results can be
+// written as JSON.
+type Result struct {
+	ID     string `json:"id"`
+	Prompt string `json:"prompt"`
+	// SessionID is the session the prompt ran in, which is deleted
+	// afterwards unless Runner.Keep is set.
+	SessionID string          `json:"sessionID,omitempty"`
+	Response  string          `json:"response,omitempty"`
+	Tokens    opencode.Tokens `json:"tokens"`
+	Cost      float64         `json:"cost"`
+	Duration  time.Duration   `json:"duration"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// Runner sends prompts to Client, each in its own session.
+type Runner struct {
+	Client opencode.Client
+	// Concurrency limits the prompts running at once. Defaults to 1.
+	Concurrency int
+	// Keep leaves the sessions on the server. By default each one is deleted
+	// once its result has been collected.
+	Keep bool
+	// Logger defaults to the client's logger.
+	Logger *slog.Logger
+}
+
+func (r *Runner) logger() *slog.Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	if oc, ok := r.Client.(*opencode.OpenCode); ok {
+		return oc.Logger()
+	}
+	return slog.Default()
+}
+
+// ReadPrompts reads JSONL prompts, skipping blank lines. Prompts without an ID
+// are named after their line number.
+func ReadPrompts(r io.Reader) ([]Prompt, error) {
+	var prompts []Prompt
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var prompt Prompt
+		if err := json.Unmarshal([]byte(text), &prompt); err != nil {
+			return nil, fmt.Errorf("failed to decode prompt on line %d: %w", line, err)
+		}
+		if prompt.ID == "" {
+			prompt.ID = fmt.Sprintf("%d", line)
+		}
+		prompts = append(prompts, prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompts: %w", err)
+	}
+	return prompts, nil
+}
+
+// Run sends every prompt and returns the results in the order of prompts.
+// Prompts that have not started when ctx is done fail with its error.
+func (r *Runner) Run(ctx context.Context, prompts []Prompt) []Result {
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]Result, len(prompts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, prompt := range prompts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				results[i] = r.run(ctx, prompt)
+			case <-ctx.Done():
+				results[i] = Result{ID: prompt.ID, Prompt: prompt.Prompt, Error: ctx.Err().Error()}
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Runner) run(ctx context.Context, prompt Prompt) Result {
+	result := Result{ID: prompt.ID, Prompt: prompt.Prompt}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	session, err := r.Client.CreateSession(ctx, opencode.SessionCreate{Title: "batch " + prompt.ID})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.SessionID = session.ID
+	log := r.logger()
+	if !r.Keep {
+		defer func() {
+			if err := r.Client.DeleteSession(context.WithoutCancel(ctx), session.ID); err != nil {
+				log.Warn("Failed to delete batch session", "id", prompt.ID, "session", session.ID, "err", err)
+			}
+		}()
+	}
+
+	message, err := r.Client.SendMessage(ctx, session.ID, opencode.Text(prompt.Prompt))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Response = message.Text()
+	result.Cost = message.Info.Cost
+	if message.Info.Tokens != nil {
+		result.Tokens = *message.Info.Tokens
+	}
+	if message.Info.Error != nil {
+		result.Error = message.Info.Error.Error()
+	}
+	log.Debug("Batch prompt finished", "id", prompt.ID, "session", session.ID, "err", result.Error)
+	return result
+}
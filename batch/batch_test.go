@@ -0,0 +1,82 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ai-shift/opencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPrompts(t *testing.T) {
+	prompts, err := ReadPrompts(strings.NewReader(`{"id":"a","prompt":"one"}
+
+{"prompt":"two"}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []Prompt{{ID: "a", Prompt: "one"}, {ID: "3", Prompt: "two"}}, prompts)
+
+	_, err = ReadPrompts(strings.NewReader("nope"))
+	assert.ErrorContains(t, err, "line 1")
+}
+
+func TestRunner(t *testing.T) {
+	var sessions, live atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session" {
+			live.Add(1)
+			fmt.Fprintf(w, `{"id":"ses_%d"}`, sessions.Add(1))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			live.Add(-1)
+			w.Write([]byte(`true`))
+			return
+		}
+		var req struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Parts[0].Text == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `{"info":{"id":"msg","role":"assistant","cost":0.25,"tokens":{"input":10,"output":5}},"parts":[{"id":"prt","type":"text","text":"echo %s"}]}`, req.Parts[0].Text)
+	}))
+	defer srv.Close()
+
+	runner := &Runner{
+		Client:      opencode.New(opencode.Config{Addr: strings.TrimPrefix(srv.URL, "http://")}),
+		Concurrency: 2,
+	}
+	results := runner.Run(context.Background(), []Prompt{{ID: "1", Prompt: "a"}, {ID: "2", Prompt: "fail"}, {ID: "3", Prompt: "b"}})
+	require.Len(t, results, 3)
+	assert.Equal(t, "echo a", results[0].Response)
+	assert.NotEmpty(t, results[1].Error)
+	assert.Equal(t, "echo b", results[2].Response)
+	assert.Equal(t, int32(3), sessions.Load())
+	assert.Zero(t, live.Load())
+
+	report := NewReport(results)
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, 20, report.Tokens.Input)
+	assert.InDelta(t, 0.5, report.Cost, 1e-9)
+
+	var out strings.Builder
+	require.NoError(t, report.WriteJSONL(&out))
+	assert.Equal(t, 3, strings.Count(out.String(), "\n"))
+
+	runner.Keep = true
+	runner.Run(context.Background(), []Prompt{{ID: "4", Prompt: "c"}})
+	assert.EqualValues(t, 1, live.Load())
+}
@@ -0,0 +1,53 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ai-shift/opencode"
+)
+
+// Report sums up the results of a run.
+type Report struct {
+	Results  []Result        `json:"results"`
+	Total    int             `json:"total"`
+	Failed   int             `json:"failed"`
+	Tokens   opencode.Tokens `json:"tokens"`
+	Cost     float64         `json:"cost"`
+	Duration time.Duration   `json:"duration"`
+}
+
+// NewReport counts the failures and adds up the tokens, costs and durations
+// of results.
+func NewReport(results []Result) Report {
+	report := Report{Results: results, Total: len(results)}
+	for _, result := range results {
+		if result.Error != "" {
+			report.Failed++
+		}
+		report.Tokens = report.Tokens.Add(result.Tokens)
+		report.Cost += result.Cost
+		report.Duration += result.Duration
+	}
+	return report
+}
+
+// WriteJSONL writes each result as a line of JSON.
+func (r Report) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, result := range r.Results {
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("failed to write result %s: %w", result.ID, err)
+		}
+	}
+	return nil
+}
+
+// WriteSummary writes the totals as a single line.
+func (r Report) WriteSummary(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "prompts: %d, failed: %d, input tokens: %d, output tokens: %d, cost: $%.4f, total time: %s\n",
+		r.Total, r.Failed, r.Tokens.Input, r.Tokens.Output, r.Cost, r.Duration)
+	return err
+}
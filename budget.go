@@ -0,0 +1,143 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Budget limits what a session may spend. Zero limits are not enforced.
+type Budget struct {
+	// MaxCost is the limit in USD.
+	MaxCost float64
+	// MaxTokens is the limit on Tokens.Total, which includes cache reads and
+	// writes.
+	MaxTokens int
+	// OnExceeded is called once the session has been aborted for exceeding
+	// the budget. The abort error is nil if the server confirmed the abort.
+	OnExceeded func(usage BudgetUsage, err error)
+}
+
+// BudgetUsage is what a session has spent so far, over all its messages.
+type BudgetUsage struct {
+	SessionID string
+	Cost      float64
+	Tokens    Tokens
+}
+
+func (b Budget) exceeded(usage BudgetUsage) bool {
+	return (b.MaxCost > 0 && usage.Cost > b.MaxCost) ||
+		(b.MaxTokens > 0 && usage.Tokens.Total() > b.MaxTokens)
+}
+
+// AbortSession stops the generation running in a session. The message being
+// generated finishes with ErrAborted.
+func (oc *OpenCode) AbortSession(ctx context.Context, sessionID string) error {
+	var aborted bool
+	if err := oc.do(ctx, http.MethodPost, fmt.Sprintf("/session/%s/abort", url.PathEscape(sessionID)), nil, &aborted); err != nil {
+		return sessionError(sessionID, err)
+	}
+	oc.log.Debug("Aborted session", "session", sessionID, "aborted", aborted)
+	return nil
+}
+
+// budgetPollInterval is how often WithBudget lists the messages once the
+// event stream has ended.
+var budgetPollInterval = 2 * time.Second
+
+// WithBudget watches a session's spending, counting the messages it already
+// has, and aborts it as soon as a finished step takes it over budget. Steps
+// finish mid-generation, so a runaway tool loop is stopped before the
+// message completes. If the event stream ends, for example because it
+// failed without Config.EventReconnect, the guard polls the session's
+// messages instead. It runs until the budget is exceeded, ctx is done or stop
+// is called.
+func (oc *OpenCode) WithBudget(ctx context.Context, sessionID string, budget Budget) (stop func(), err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	events := oc.SubscribeFiltered(ctx, EventFilter{SessionID: sessionID, Types: []string{EventMessagePartUpdated}})
+	messages, err := oc.ListMessages(ctx, sessionID)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	steps := make(map[string]*StepFinishPart)
+	record := func(messages []MessageWithParts) {
+		for i := range messages {
+			for _, step := range messages[i].Steps() {
+				steps[step.ID] = step
+			}
+		}
+	}
+	record(messages)
+	usage := func() BudgetUsage {
+		u := BudgetUsage{SessionID: sessionID}
+		for _, step := range steps {
+			u.Cost += step.Cost
+			u.Tokens = u.Tokens.Add(step.Tokens)
+		}
+		return u
+	}
+
+	// check aborts the session and reports whether it is over budget.
+	check := func() bool {
+		u := usage()
+		if !budget.exceeded(u) {
+			return false
+		}
+		oc.log.Warn("Session exceeded its budget", "session", sessionID, "cost", u.Cost, "tokens", u.Tokens.Total())
+		err := oc.AbortSession(ctx, sessionID)
+		if budget.OnExceeded != nil {
+			budget.OnExceeded(u, err)
+		}
+		return true
+	}
+
+	go func() {
+		defer cancel()
+		if check() {
+			return
+		}
+		// poll replaces the events once the event stream ends, so the budget
+		// is still enforced
+		var poll <-chan time.Time
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					if ctx.Err() != nil {
+						return
+					}
+					oc.log.Warn("Event stream ended, polling session spending instead", "session", sessionID)
+					events = nil
+					ticker := time.NewTicker(budgetPollInterval)
+					defer ticker.Stop()
+					poll = ticker.C
+					continue
+				}
+				step, ok := event.(*MessagePartUpdatedEvent).Part.(*StepFinishPart)
+				if !ok {
+					continue
+				}
+				steps[step.ID] = step
+			case <-poll:
+				messages, err := oc.ListMessages(ctx, sessionID)
+				if err != nil {
+					if ctx.Err() == nil {
+						oc.log.Warn("Failed to poll session spending", "session", sessionID, "err", err)
+					}
+					continue
+				}
+				record(messages)
+			case <-ctx.Done():
+				return
+			}
+			if check() {
+				return
+			}
+		}
+	}()
+	return cancel, nil
+}
@@ -0,0 +1,144 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBudget(t *testing.T) {
+	events := make(chan string)
+	aborted := make(chan string, 1)
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case event := <-events:
+					fmt.Fprintf(w, "data: %s\n\n", event)
+					w.(http.Flusher).Flush()
+				}
+			}
+		case "/session/ses_1/message":
+			w.Write([]byte(messagesJSON))
+		case "/session/ses_1/abort":
+			assert.Equal(t, http.MethodPost, r.Method)
+			aborted <- "ses_1"
+			w.Write([]byte(`true`))
+		}
+	})
+
+	exceeded := make(chan BudgetUsage, 1)
+	stop, err := oc.WithBudget(context.Background(), "ses_1", Budget{
+		MaxCost: 1,
+		OnExceeded: func(usage BudgetUsage, err error) {
+			assert.NoError(t, err)
+			exceeded <- usage
+		},
+	})
+	require.NoError(t, err)
+	defer stop()
+
+	step := `{"type":"message.part.updated","properties":{"part":{"id":"%s","sessionID":"ses_1","messageID":"msg_3","type":"step-finish","cost":%s,"tokens":{"input":5,"output":5}}}}`
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_8","sessionID":"ses_1","messageID":"msg_3","type":"text","text":"hi"}}}`
+	events <- fmt.Sprintf(step, "prt_9", "0.25")
+	// A repeated update of the same step is not counted twice.
+	events <- fmt.Sprintf(step, "prt_9", "0.25")
+	select {
+	case <-aborted:
+		t.Fatal("aborted within budget")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	events <- fmt.Sprintf(step, "prt_10", "0.5")
+	select {
+	case usage := <-exceeded:
+		assert.Equal(t, "ses_1", <-aborted)
+		assert.Equal(t, "ses_1", usage.SessionID)
+		assert.InDelta(t, 1.25, usage.Cost, 1e-9)
+		assert.Equal(t, 50, usage.Tokens.Total())
+	case <-time.After(time.Second):
+		t.Fatal("budget not enforced")
+	}
+}
+
+func TestWithBudgetAlreadyExceeded(t *testing.T) {
+	aborted := make(chan struct{}, 1)
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			sseHandler()(w, r)
+		case "/session/ses_1/message":
+			w.Write([]byte(messagesJSON))
+		case "/session/ses_1/abort":
+			aborted <- struct{}{}
+			w.Write([]byte(`true`))
+		}
+	})
+
+	exceeded := make(chan BudgetUsage, 1)
+	_, err := oc.WithBudget(context.Background(), "ses_1", Budget{
+		MaxTokens:  20,
+		OnExceeded: func(usage BudgetUsage, err error) { exceeded <- usage },
+	})
+	require.NoError(t, err)
+	select {
+	case usage := <-exceeded:
+		<-aborted
+		assert.Equal(t, 30, usage.Tokens.Total())
+	case <-time.After(time.Second):
+		t.Fatal("budget not enforced")
+	}
+}
+
+func TestWithBudgetPollsWithoutEvents(t *testing.T) {
+	interval := budgetPollInterval
+	budgetPollInterval = 20 * time.Millisecond
+	defer func() { budgetPollInterval = interval }()
+
+	var lists atomic.Int32
+	aborted := make(chan struct{}, 1)
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			// The stream ends right away and is not reconnected
+			sseHandler()(w, r)
+		case "/session/ses_1/message":
+			if lists.Add(1) == 1 {
+				w.Write([]byte(messagesJSON))
+				return
+			}
+			w.Write([]byte(`[{"info":{"id":"msg_3","sessionID":"ses_1","role":"assistant"},"parts":[
+				{"id":"prt_9","sessionID":"ses_1","messageID":"msg_3","type":"step-finish","cost":2,"tokens":{"input":1}}
+			]}]`))
+		case "/session/ses_1/abort":
+			aborted <- struct{}{}
+			w.Write([]byte(`true`))
+		}
+	})
+
+	exceeded := make(chan BudgetUsage, 1)
+	stop, err := oc.WithBudget(context.Background(), "ses_1", Budget{
+		MaxCost:    1,
+		OnExceeded: func(usage BudgetUsage, err error) { exceeded <- usage },
+	})
+	require.NoError(t, err)
+	defer stop()
+	select {
+	case usage := <-exceeded:
+		<-aborted
+		assert.InDelta(t, 2.5, usage.Cost, 1e-9)
+	case <-time.After(2 * time.Second):
+		t.Fatal("budget not enforced after the event stream ended")
+	}
+}
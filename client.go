@@ -0,0 +1,234 @@
+package opencode
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIError is returned for non-2xx responses. Use errors.Is with ErrNotFound
+// and ErrUnauthorized to classify it, or errors.As for the details.
+type APIError struct {
+	Status int
+	// Code is the server's error name, e.g. "NotFoundError".
+	Code    string
+	Message string
+	// Body is the start of the raw response body.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("unexpected status code: %d", e.Status)
+	switch {
+	case e.Code != "" && e.Message != "":
+		msg += fmt.Sprintf(" (%s: %s)", e.Code, e.Message)
+	case e.Code != "" || e.Message != "":
+		msg += fmt.Sprintf(" (%s)", e.Code+e.Message)
+	}
+	return msg
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden
+	}
+	return false
+}
+
+// newAPIError reads the error body, which opencode sends as
+// {"name": ..., "data": {"message": ...}}.
+func newAPIError(resp *http.Response) *APIError {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &APIError{Status: resp.StatusCode, Body: string(data)}
+	var body struct {
+		Name string `json:"name"`
+		Data struct {
+			Message string `json:"message"`
+		} `json:"data"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil {
+		apiErr.Code = body.Name
+		apiErr.Message = cmp.Or(body.Data.Message, body.Error)
+	} else if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
+
+// RoundTripFunc sends a request. It implements http.RoundTripper, so it can
+// also be passed to WithTransport.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps every request to the server, including health checks and
+// the event stream, e.g. to add headers, log or inject faults.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// sendWith sends req through client wrapped in Config.Middleware, the first
+// middleware being the outermost.
+func (oc *OpenCode) sendWith(client *http.Client, req *http.Request) (resp *http.Response, err error) {
+	if tracer := oc.config.Tracer; tracer != nil {
+		route := Route(req)
+		ctx, span := tracer.StartSpan(req.Context(), req.Method+" "+route)
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("http.route", route)
+		req = req.WithContext(ctx)
+		tracer.Inject(ctx, req.Header)
+		defer func() {
+			spanErr := err
+			if err == nil {
+				span.SetAttribute("http.response.status_code", resp.StatusCode)
+				if resp.StatusCode >= 400 {
+					spanErr = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+				}
+			}
+			span.End(spanErr)
+		}()
+	}
+
+	next := RoundTripFunc(client.Do)
+	for i := len(oc.config.Middleware) - 1; i >= 0; i-- {
+		next = oc.config.Middleware[i](next)
+	}
+	if oc.config.Metrics == nil {
+		return next(req)
+	}
+	start := time.Now()
+	resp, err = next(req)
+	status := 0
+	if err == nil {
+		status = resp.StatusCode
+	}
+	oc.config.Metrics.ObserveRequest(req.Method, Route(req), status, time.Since(start))
+	return resp, err
+}
+
+type directoryKey struct{}
+
+// WithDirectory scopes every request made with the returned context to the
+// given project directory instead of the server's working directory.
+func WithDirectory(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, directoryKey{}, dir)
+}
+
+func (oc *OpenCode) url(ctx context.Context, path string) string {
+	var u string
+	addr := oc.Addr()
+	switch {
+	case oc.config.Socket != "":
+		// The transport dials the socket, the host is only for the request line
+		u = "http://opencode" + path
+	case strings.Contains(addr, "://"):
+		u = strings.TrimSuffix(addr, "/") + path
+	default:
+		u = "http://" + addr + path
+	}
+	if dir, ok := ctx.Value(directoryKey{}).(string); ok && dir != "" {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		u += sep + "directory=" + url.QueryEscape(dir)
+	}
+	return u
+}
+
+// Do calls an endpoint this package does not wrap yet. It routes the request
+// like the other methods, including the WithDirectory parameter, encodes body
+// as JSON unless it is nil and decodes the JSON response into out unless it
+// is nil. path may include a query string. Config.RequestTimeout applies
+// unless ctx has a deadline.
+func (oc *OpenCode) Do(ctx context.Context, method, path string, body, out any) error {
+	return oc.do(ctx, method, path, body, out)
+}
+
+// newRequest builds a request for a server path with the configured headers.
+func (oc *OpenCode) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(withRoute(ctx, path), method, oc.url(ctx, path), body)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range oc.config.Headers {
+		req.Header.Set(key, value)
+	}
+	if oc.config.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+oc.config.BearerToken)
+	}
+	return req, nil
+}
+
+// do sends a request bounded by Config.RequestTimeout unless ctx already has
+// a deadline.
+func (oc *OpenCode) do(ctx context.Context, method, path string, body, out any) error {
+	timeout := oc.config.RequestTimeout
+	if timeout == 0 {
+		timeout = DefaultRequestTimeout
+	}
+	if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return oc.send(ctx, method, path, body, out)
+}
+
+// doPrompt sends a request that lasts as long as the agent works on it, so
+// only ctx bounds it.
+func (oc *OpenCode) doPrompt(ctx context.Context, method, path string, body, out any) error {
+	return oc.send(ctx, method, path, body, out)
+}
+
+func (oc *OpenCode) send(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := oc.newRequest(ctx, method, path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oc.sendWith(oc.client, req)
+	if err != nil {
+		if ctx.Err() == nil && oc.ExitState() != nil {
+			return fmt.Errorf("failed to send request: %w: %w", ErrNotRunning, err)
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *OpenCode {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return New(Config{Addr: strings.TrimPrefix(srv.URL, "http://")})
+}
+
+func TestDoUnexpectedStatus(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	err := oc.do(context.Background(), http.MethodGet, "/foo", nil, nil)
+	assert.EqualError(t, err, "unexpected status code: 500")
+}
+
+func TestAPIError(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"name":"NotFoundError","data":{"message":"Session not found"}}`))
+		case "/session":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("missing token\n"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"data":{},"error":"invalid body","success":false}`))
+		}
+	})
+
+	_, err := oc.GetSession(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+	assert.ErrorIs(t, err, ErrNotFound)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, &APIError{Status: 404, Code: "NotFoundError", Message: "Session not found", Body: `{"name":"NotFoundError","data":{"message":"Session not found"}}`}, apiErr)
+
+	_, err = oc.ListSessions(context.Background())
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.ErrorContains(t, err, "unexpected status code: 401 (missing token)")
+
+	err = oc.Do(context.Background(), http.MethodPost, "/tui/foo", nil, nil)
+	assert.EqualError(t, err, "unexpected status code: 400 (invalid body)")
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
+
+func TestErrNotRunning(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	oc := New(Config{Addr: addr})
+	_, err = oc.ListSessions(context.Background())
+	assert.NotErrorIs(t, err, ErrNotRunning, "servers not started by Start are not tracked")
+
+	oc.exitState = &ExitState{Code: 1}
+	_, err = oc.ListSessions(context.Background())
+	assert.ErrorIs(t, err, ErrNotRunning)
+
+	err = oc.WaitForReady(context.Background(), 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrNotReady)
+}
+
+func TestDo(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/experimental/tool", r.URL.Path)
+		assert.Equal(t, "go", r.URL.Query().Get("language"))
+		assert.Equal(t, "/work", r.URL.Query().Get("directory"))
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "x", body["name"])
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	var out struct{ OK bool }
+	ctx := WithDirectory(context.Background(), "/work")
+	require.NoError(t, oc.Do(ctx, http.MethodPost, "/experimental/tool?language=go", map[string]string{"name": "x"}, &out))
+	assert.True(t, out.OK)
+}
+
+func TestWithDirectory(t *testing.T) {
+	var queries []string
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("directory"))
+		switch r.URL.Path {
+		case "/session":
+			if r.Method == http.MethodPost {
+				w.Write([]byte(`{"id":"ses_1"}`))
+				return
+			}
+			w.Write([]byte(`[]`))
+		default:
+			w.Write([]byte(`{"info":{"id":"msg"},"parts":[]}`))
+		}
+	})
+
+	ctx := WithDirectory(context.Background(), "/projects/a b")
+	_, err := oc.ListSessions(ctx)
+	assert.NoError(t, err)
+	_, err = oc.CreateSession(ctx, SessionCreate{})
+	assert.NoError(t, err)
+	_, err = oc.SendMessage(ctx, "ses_1", Text("hi"))
+	assert.NoError(t, err)
+	_, err = oc.ListSessions(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"/projects/a b", "/projects/a b", "/projects/a b", ""}, queries)
+}
+
+func TestURLWithExistingQuery(t *testing.T) {
+	oc := New(Config{Addr: "127.0.0.1:1"})
+	ctx := WithDirectory(context.Background(), "/p")
+	assert.Equal(t, "http://127.0.0.1:1/find?pattern=x&directory=%2Fp", oc.url(ctx, "/find?pattern=x"))
+}
+
+func TestSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "opencode.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session" {
+			w.Write([]byte(`[{"id":"ses_1"}]`))
+		}
+	}))
+	srv.Listener = listener
+	srv.Start()
+	defer srv.Close()
+
+	oc := New(Config{Socket: socket})
+	require.NoError(t, oc.WaitForReady(context.Background(), 5*time.Second))
+	sessions, err := oc.ListSessions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ses_1", sessions[0].ID)
+}
+
+func TestRemoteServer(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.URL.Path+" "+r.Header.Get("Authorization")+" "+r.Header.Get("X-Tenant"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/opencode/session":
+			w.Write([]byte(`[{"id":"ses_1"}]`))
+		case "/opencode/event":
+			sseHandler(`{"type":"server.connected","properties":{}}`)(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	oc := New(Config{
+		Addr:        srv.URL + "/opencode/",
+		Headers:     map[string]string{"X-Tenant": "acme", "Authorization": "Basic overridden"},
+		BearerToken: "tok",
+	}, WithHTTPClient(srv.Client()))
+
+	require.NoError(t, oc.WaitForReady(context.Background(), 5*time.Second))
+	sessions, err := oc.ListSessions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "ses_1", sessions[0].ID)
+	ctx, cancel := context.WithCancel(context.Background())
+	oc.StreamEvents(ctx, func(Event) { cancel() })
+
+	assert.Equal(t, []string{
+		"/opencode/global/health Bearer tok acme",
+		"/opencode/session Bearer tok acme",
+		"/opencode/event Bearer tok acme",
+	}, seen)
+}
+
+func TestWithTransport(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+	var proxied int
+	client := &http.Client{Timeout: time.Second}
+	WithHTTPClient(client)(oc)
+	WithTransport(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		proxied++
+		return http.DefaultTransport.RoundTrip(req)
+	}))(oc)
+
+	_, err := oc.ListSessions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, proxied)
+	assert.Equal(t, time.Second, oc.client.Timeout)
+	assert.Nil(t, client.Transport, "the caller's client must not be modified")
+}
+
+func TestRequestTimeout(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		if r.URL.Path == "/session" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`{"info":{"id":"msg","role":"assistant"},"parts":[]}`))
+	})
+	oc.config.RequestTimeout = 50 * time.Millisecond
+
+	_, err := oc.ListSessions(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A deadline on the context replaces the default timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = oc.ListSessions(ctx)
+	assert.NoError(t, err)
+
+	// Prompts wait for the agent however long it takes
+	_, err = oc.SendMessage(context.Background(), "ses_1", Text("hi"))
+	assert.NoError(t, err)
+}
+
+func TestStreamIgnoresClientTimeout(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("data: {\"type\":\"server.connected\",\"properties\":{}}\n\n"))
+	})
+	oc = New(oc.config, WithHTTPClient(&http.Client{Timeout: 50 * time.Millisecond}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var received bool
+	err := oc.StreamEvents(ctx, func(Event) {
+		received = true
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.True(t, received)
+}
+
+func TestContextCancelsRequest(t *testing.T) {
+	cancelled := make(chan struct{}, 2)
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a closed connection once the body is read
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	for _, call := range []func(context.Context) error{
+		func(ctx context.Context) error { _, err := oc.ListSessions(ctx); return err },
+		func(ctx context.Context) error { _, err := oc.SendMessage(ctx, "ses_1", Text("hi")); return err },
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		assert.ErrorIs(t, call(ctx), context.Canceled)
+		select {
+		case <-cancelled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("server did not see the cancellation")
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "trace-1", r.Header.Get("Traceparent"))
+		switch r.URL.Path {
+		case "/event":
+			sseHandler(`{"type":"server.connected","properties":{}}`)(w, r)
+		default:
+			w.Write([]byte(`[]`))
+		}
+	})
+	var calls []string
+	oc.config.Middleware = []Middleware{
+		func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, "outer "+req.URL.Path)
+				req.Header.Set("Traceparent", "trace-1")
+				return next(req)
+			}
+		},
+		func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, "inner "+req.Header.Get("Traceparent"))
+				if req.URL.Path == "/session/fail" {
+					return nil, errors.New("injected fault")
+				}
+				return next(req)
+			}
+		},
+	}
+
+	require.NoError(t, oc.WaitForReady(context.Background(), 5*time.Second))
+	_, err := oc.ListSessions(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	oc.StreamEvents(ctx, func(Event) { cancel() })
+	_, err = oc.GetSession(context.Background(), "fail")
+	assert.ErrorContains(t, err, "injected fault")
+
+	assert.Equal(t, []string{
+		"outer /global/health", "inner trace-1",
+		"outer /session", "inner trace-1",
+		"outer /event", "inner trace-1",
+		"outer /session/fail", "inner trace-1",
+	}, calls)
+}
@@ -47,6 +47,8 @@ func main() {
 	cfg := opencode.Config{
 		CWD:      sessionDir,
 		ConfigFS: subFS,
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
 	}
 
 	oc := opencode.New(cfg)
@@ -58,8 +60,7 @@ func main() {
 			log.Fatalf("Failed to start opencode: %v", err)
 		}
 	}()
-	defer oc.Stop()
-	defer oc.Cleanup()
+	defer oc.Close(context.Background())
 
 	if err := oc.WaitForReady(context.Background()); err != nil {
 		log.Fatalf("Failed to connect to opencode: %v", err)
@@ -68,10 +69,15 @@ func main() {
 	fmt.Printf("OpenCode server is ready at: http://%s\n", oc.Addr())
 	fmt.Println("Press Ctrl+C to stop the server")
 
-	// Wait for interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	// Print events until interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := oc.StreamEvents(ctx, func(event opencode.Event) {
+		fmt.Printf("event: %s\n", event.EventType())
+	}); err != nil && ctx.Err() == nil {
+		log.Printf("Event stream failed: %v", err)
+		<-ctx.Done()
+	}
 
 	fmt.Println("\nStopping OpenCode server...")
 }
@@ -0,0 +1,98 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Command is a slash command defined in config or in a commands directory.
+type Command struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Agent       string `json:"agent,omitempty"`
+	// Model is "provider/model", empty for the session's model.
+	Model    string `json:"model,omitempty"`
+	Template string `json:"template"`
+	// Subtask runs the command in a sub-session.
+	Subtask bool `json:"subtask,omitempty"`
+}
+
+type commandRequest struct {
+	Command   string `json:"command"`
+	Arguments string `json:"arguments"`
+}
+
+type shellRequest struct {
+	Agent   string `json:"agent"`
+	Command string `json:"command"`
+}
+
+func (oc *OpenCode) ListCommands(ctx context.Context) ([]Command, error) {
+	var commands []Command
+	if err := oc.do(ctx, http.MethodGet, "/command", nil, &commands); err != nil {
+		return nil, fmt.Errorf("failed to list commands: %w", err)
+	}
+	return commands, nil
+}
+
+// RunCommand runs a slash command in a session, as if "/name args" had been
+// typed, and returns the assistant's response. args replaces $ARGUMENTS in
+// the command's template.
+func (oc *OpenCode) RunCommand(ctx context.Context, sessionID, name, args string) (*MessageWithParts, error) {
+	if oc.config.QueueSends {
+		release, err := oc.acquireSession(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	oc.log.Debug("Running command", "session", sessionID, "command", name)
+	var message MessageWithParts
+	req := commandRequest{Command: name, Arguments: args}
+	if err := oc.doPrompt(ctx, http.MethodPost, fmt.Sprintf("/session/%s/command", url.PathEscape(sessionID)), req, &message); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	return &message, nil
+}
+
+// RunShell runs a shell command in the session's directory, as if "!command"
+// had been typed, and returns the message holding its bash tool part. The
+// command is attributed to maybeAgent, "build" by default.
+func (oc *OpenCode) RunShell(ctx context.Context, sessionID, command string, maybeAgent ...string) (*MessageWithParts, error) {
+	req := shellRequest{Agent: "build", Command: command}
+	if len(maybeAgent) > 0 {
+		req.Agent = maybeAgent[0]
+	}
+
+	if oc.config.QueueSends {
+		release, err := oc.acquireSession(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	oc.log.Debug("Running shell command", "session", sessionID)
+	var raw json.RawMessage
+	if err := oc.doPrompt(ctx, http.MethodPost, fmt.Sprintf("/session/%s/shell", url.PathEscape(sessionID)), req, &raw); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	var message MessageWithParts
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return nil, fmt.Errorf("failed to decode shell response: %w", err)
+	}
+	if message.Info.ID != "" {
+		return &message, nil
+	}
+	// Servers that return only the message info need a second request for
+	// the parts.
+	var info Message
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode shell response: %w", err)
+	}
+	return oc.GetMessage(ctx, sessionID, info.ID)
+}
@@ -0,0 +1,66 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCommands(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/command", r.URL.Path)
+		w.Write([]byte(`[{"name":"test","description":"Run the tests","template":"Run go test $ARGUMENTS","agent":"build","subtask":true}]`))
+	})
+
+	commands, err := oc.ListCommands(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Command{{Name: "test", Description: "Run the tests", Template: "Run go test $ARGUMENTS", Agent: "build", Subtask: true}}, commands)
+}
+
+func TestRunCommand(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session/missing/command" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/session/ses_1/command", r.URL.Path)
+		var req commandRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, commandRequest{Command: "test", Arguments: "./..."}, req)
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant"},"parts":[{"id":"prt_1","type":"text","text":"all green"}]}`))
+	})
+
+	message, err := oc.RunCommand(context.Background(), "ses_1", "test", "./...")
+	require.NoError(t, err)
+	assert.Equal(t, "msg_2", message.Info.ID)
+
+	_, err = oc.RunCommand(context.Background(), "missing", "test", "")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestRunShell(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/ses_1/shell":
+			var req shellRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, shellRequest{Agent: "plan", Command: "git status"}, req)
+			// Older servers return only the assistant message info
+			w.Write([]byte(`{"id":"msg_3","sessionID":"ses_1","role":"assistant"}`))
+		case "/session/ses_1/message/msg_3":
+			w.Write([]byte(`{"info":{"id":"msg_3","role":"assistant"},"parts":[{"id":"prt_1","type":"tool","tool":"bash","state":{"status":"completed","output":"clean"}}]}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+
+	message, err := oc.RunShell(context.Background(), "ses_1", "git status", "plan")
+	require.NoError(t, err)
+	require.Len(t, message.Parts, 1)
+	assert.Equal(t, "bash", message.Parts[0].(*ToolPart).Tool)
+}
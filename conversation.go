@@ -0,0 +1,104 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Conversation is a chat bound to one session. It keeps the session's
+// messages locally, so History does not call the server.
+type Conversation struct {
+	client    Client
+	sessionID string
+	// opts are applied to every message, before the options of Send
+	opts []MessageOption
+
+	mu      sync.Mutex
+	history []MessageWithParts
+}
+
+// NewConversation creates a session for the conversation. opts, such as
+// WithAgent, apply to every message sent in it.
+func NewConversation(ctx context.Context, client Client, create SessionCreate, opts ...MessageOption) (*Conversation, error) {
+	session, err := client.CreateSession(ctx, create)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversation{client: client, sessionID: session.ID, opts: opts}, nil
+}
+
+// ResumeConversation continues an existing session, loading its messages.
+func ResumeConversation(ctx context.Context, client Client, sessionID string, opts ...MessageOption) (*Conversation, error) {
+	c := &Conversation{client: client, sessionID: sessionID, opts: opts}
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conversation) SessionID() string {
+	return c.sessionID
+}
+
+// Send sends prompt and returns the assistant's answer once it has finished.
+// A failed answer is returned along with its error.
+func (c *Conversation) Send(ctx context.Context, prompt string, opts ...MessageOption) (*MessageWithParts, error) {
+	opts = append(slices.Concat(c.opts, opts), Text(prompt))
+	answer, err := c.client.SendMessage(ctx, c.sessionID, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Refresh(ctx); err != nil {
+		return answer, err
+	}
+	if answer.Info.Error != nil {
+		return answer, fmt.Errorf("assistant message %s failed: %w", answer.Info.ID, answer.Info.Error)
+	}
+	return answer, nil
+}
+
+// Stream sends prompt and writes the answer's text to w as it is generated.
+// The conversation's message options do not apply, as with StreamResponse.
+func (c *Conversation) Stream(ctx context.Context, prompt string, w io.Writer) error {
+	if err := c.client.StreamResponse(ctx, c.sessionID, prompt, w); err != nil {
+		return err
+	}
+	return c.Refresh(ctx)
+}
+
+// Refresh reloads the history from the server, e.g. after messages were sent
+// to the session elsewhere.
+func (c *Conversation) Refresh(ctx context.Context) error {
+	messages, err := c.client.ListMessages(ctx, c.sessionID)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.history = messages
+	c.mu.Unlock()
+	return nil
+}
+
+// History returns the messages of the conversation, oldest first, including
+// the assistant's intermediate tool call steps.
+func (c *Conversation) History() []MessageWithParts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.history)
+}
+
+// LastAssistantText returns the text of the latest assistant message, or ""
+// if there is none yet.
+func (c *Conversation) LastAssistantText() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.history) - 1; i >= 0; i-- {
+		if c.history[i].Info.Role == RoleAssistant {
+			return c.history[i].Text()
+		}
+	}
+	return ""
+}
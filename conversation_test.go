@@ -0,0 +1,68 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConversation(t *testing.T) {
+	var messages []map[string]any
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /session":
+			w.Write([]byte(`{"id":"ses_1"}`))
+		case "POST /session/ses_1/message":
+			var req struct {
+				Agent string `json:"agent"`
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "plan", req.Agent)
+			n := len(messages)
+			user := map[string]any{
+				"info":  map[string]any{"id": fmt.Sprintf("msg_%d", n), "sessionID": "ses_1", "role": "user"},
+				"parts": []any{map[string]any{"type": "text", "text": req.Parts[0].Text}},
+			}
+			answer := map[string]any{
+				"info":  map[string]any{"id": fmt.Sprintf("msg_%d", n+1), "sessionID": "ses_1", "role": "assistant", "parentID": fmt.Sprintf("msg_%d", n)},
+				"parts": []any{map[string]any{"type": "text", "text": "re: " + req.Parts[0].Text}},
+			}
+			messages = append(messages, user, answer)
+			json.NewEncoder(w).Encode(answer)
+		case "GET /session/ses_1/message":
+			json.NewEncoder(w).Encode(messages)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	ctx := context.Background()
+	conv, err := NewConversation(ctx, oc, SessionCreate{}, WithAgent("plan"))
+	require.NoError(t, err)
+	assert.Equal(t, "ses_1", conv.SessionID())
+	assert.Empty(t, conv.LastAssistantText())
+
+	answer, err := conv.Send(ctx, "hello")
+	require.NoError(t, err)
+	assert.Equal(t, "re: hello", answer.Text())
+	_, err = conv.Send(ctx, "again")
+	require.NoError(t, err)
+
+	history := conv.History()
+	require.Len(t, history, 4)
+	assert.Equal(t, "hello", history[0].Text())
+	assert.Equal(t, RoleAssistant, history[3].Info.Role)
+	assert.Equal(t, "re: again", conv.LastAssistantText())
+
+	resumed, err := ResumeConversation(ctx, oc, "ses_1")
+	require.NoError(t, err)
+	assert.Len(t, resumed.History(), 4)
+}
@@ -0,0 +1,174 @@
+package opencode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type FileDiff struct {
+	OldFile string
+	NewFile string
+	Hunks   []Hunk
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Section  string
+	Lines    []DiffLine
+}
+
+type DiffLine struct {
+	// Op is ' ' for context, '+' for added and '-' for removed lines.
+	Op   byte
+	Text string
+}
+
+func (h Hunk) Added() int   { return h.count('+') }
+func (h Hunk) Removed() int { return h.count('-') }
+
+func (h Hunk) count(op byte) int {
+	n := 0
+	for _, line := range h.Lines {
+		if line.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+func ParseDiff(text string) ([]FileDiff, error) {
+	var diffs []FileDiff
+	var hunk *Hunk
+	oldLeft, newLeft := 0, 0
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if hunk != nil && (oldLeft > 0 || newLeft > 0) {
+			op, text := byte(' '), ""
+			if line != "" {
+				op, text = line[0], line[1:]
+			}
+			switch op {
+			case ' ':
+				oldLeft--
+				newLeft--
+			case '-':
+				oldLeft--
+			case '+':
+				newLeft--
+			case '\\':
+				continue
+			default:
+				return nil, fmt.Errorf("line %d: unexpected line in hunk %q", i+1, line)
+			}
+			hunk.Lines = append(hunk.Lines, DiffLine{Op: op, Text: text})
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			diffs = append(diffs, FileDiff{OldFile: diffFileName(line[4:])})
+			hunk = nil
+		case strings.HasPrefix(line, "+++ ") && len(diffs) > 0:
+			diffs[len(diffs)-1].NewFile = diffFileName(line[4:])
+		case strings.HasPrefix(line, "@@ "):
+			if len(diffs) == 0 {
+				return nil, fmt.Errorf("line %d: hunk without file header", i+1)
+			}
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			file := &diffs[len(diffs)-1]
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+			oldLeft, newLeft = h.OldLines, h.NewLines
+		}
+	}
+	if oldLeft > 0 || newLeft > 0 {
+		return nil, fmt.Errorf("diff ends in the middle of a hunk")
+	}
+	return diffs, nil
+}
+
+func diffFileName(name string) string {
+	name, _, _ = strings.Cut(name, "\t")
+	if name == "/dev/null" {
+		return name
+	}
+	for _, prefix := range []string{"a/", "b/"} {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+func parseHunkHeader(line string) (Hunk, error) {
+	rest, ok := strings.CutPrefix(line, "@@ ")
+	ranges, section, ok2 := strings.Cut(rest, " @@")
+	if !ok || !ok2 {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+	oldRange, newRange, ok := strings.Cut(ranges, " ")
+	if !ok || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return Hunk{}, fmt.Errorf("malformed hunk header %q", line)
+	}
+
+	var h Hunk
+	var err error
+	if h.OldStart, h.OldLines, err = parseRange(oldRange[1:]); err != nil {
+		return Hunk{}, err
+	}
+	if h.NewStart, h.NewLines, err = parseRange(newRange[1:]); err != nil {
+		return Hunk{}, err
+	}
+	h.Section = strings.TrimPrefix(section, " ")
+	return h, nil
+}
+
+func parseRange(r string) (int, int, error) {
+	startStr, linesStr, hasLines := strings.Cut(r, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed hunk range %q", r)
+	}
+	if !hasLines {
+		return start, 1, nil
+	}
+	lines, err := strconv.Atoi(linesStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("malformed hunk range %q", r)
+	}
+	return start, lines, nil
+}
+
+func RenderDiff(diffs []FileDiff) string {
+	var sb strings.Builder
+	for _, file := range diffs {
+		fmt.Fprintf(&sb, "--- %s\n+++ %s\n", diffPath("a/", file.OldFile), diffPath("b/", file.NewFile))
+		for _, h := range file.Hunks {
+			fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+			if h.Section != "" {
+				sb.WriteString(" " + h.Section)
+			}
+			sb.WriteByte('\n')
+			for _, line := range h.Lines {
+				sb.WriteByte(line.Op)
+				sb.WriteString(line.Text)
+				sb.WriteByte('\n')
+			}
+		}
+	}
+	return sb.String()
+}
+
+func diffPath(prefix, name string) string {
+	if name == "/dev/null" {
+		return name
+	}
+	return prefix + name
+}
@@ -0,0 +1,72 @@
+package opencode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleDiff = `--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@ package main
+ package main
+ 
+--- removed comment
++// added comment
+ func main() {}
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package new
+`
+
+func TestParseDiff(t *testing.T) {
+	diffs, err := ParseDiff(sampleDiff)
+	require.NoError(t, err)
+	require.Len(t, diffs, 2)
+
+	main := diffs[0]
+	assert.Equal(t, "main.go", main.OldFile)
+	assert.Equal(t, "main.go", main.NewFile)
+	require.Len(t, main.Hunks, 1)
+	hunk := main.Hunks[0]
+	assert.Equal(t, Hunk{OldStart: 1, OldLines: 4, NewStart: 1, NewLines: 4, Section: "package main", Lines: hunk.Lines}, hunk)
+	assert.Equal(t, DiffLine{Op: '-', Text: "-- removed comment"}, hunk.Lines[2])
+	assert.Equal(t, 1, hunk.Added())
+	assert.Equal(t, 1, hunk.Removed())
+
+	assert.Equal(t, "/dev/null", diffs[1].OldFile)
+	assert.Equal(t, "new.go", diffs[1].NewFile)
+	assert.Equal(t, 1, diffs[1].Hunks[0].NewLines)
+
+	assert.Equal(t, `--- a/main.go
++++ b/main.go
+@@ -1,4 +1,4 @@ package main
+ package main
+ 
+--- removed comment
++// added comment
+ func main() {}
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,1 @@
++package new
+`, RenderDiff(diffs))
+}
+
+func TestParseDiffTruncated(t *testing.T) {
+	_, err := ParseDiff("--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n a\n")
+	assert.EqualError(t, err, "diff ends in the middle of a hunk")
+}
+
+func TestToolPartDiff(t *testing.T) {
+	part := &ToolPart{Metadata: map[string]any{"diff": sampleDiff}}
+	diffs, err := part.Diff()
+	require.NoError(t, err)
+	assert.Len(t, diffs, 2)
+
+	diffs, err = (&ToolPart{}).Diff()
+	assert.NoError(t, err)
+	assert.Nil(t, diffs)
+}
@@ -0,0 +1,59 @@
+package opencode
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotRunning is returned by requests to a server started by Start
+	// that has since exited.
+	ErrNotRunning = errors.New("opencode is not running")
+	ErrNotReady   = errors.New("opencode is not ready")
+	ErrClosed     = errors.New("opencode client closed")
+)
+
+var (
+	ErrProviderAuth    = errors.New("provider authentication failed")
+	ErrAborted         = errors.New("message aborted")
+	ErrOutputLength    = errors.New("message output length exceeded")
+	ErrContextOverflow = errors.New("context window exceeded")
+)
+
+// MessageError is the named error the server attaches to failed assistant
+// messages and session.error events. Use errors.Is with the Err* sentinels
+// above to classify it.
+type MessageError struct {
+	Name string           `json:"name"`
+	Data MessageErrorData `json:"data"`
+}
+
+type MessageErrorData struct {
+	Message     string `json:"message,omitempty"`
+	ProviderID  string `json:"providerID,omitempty"`
+	StatusCode  int    `json:"statusCode,omitempty"`
+	IsRetryable bool   `json:"isRetryable,omitempty"`
+}
+
+func (e *MessageError) Error() string {
+	if e.Data.Message == "" {
+		return e.Name
+	}
+	return fmt.Sprintf("%s: %s", e.Name, e.Data.Message)
+}
+
+func (e *MessageError) Is(target error) bool {
+	switch target {
+	case ErrProviderAuth:
+		return e.Name == "ProviderAuthError"
+	case ErrAborted:
+		return e.Name == "MessageAbortedError"
+	case ErrOutputLength:
+		return e.Name == "MessageOutputLengthError"
+	case ErrContextOverflow:
+		return e.Name == "ContextOverflowError"
+	}
+	return false
+}
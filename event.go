@@ -0,0 +1,240 @@
+package opencode
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Event types as sent by the server and returned by Event.EventType.
+const (
+	EventServerConnected    = "server.connected"
+	EventMessageUpdated     = "message.updated"
+	EventMessagePartUpdated = "message.part.updated"
+	EventMessageRemoved     = "message.removed"
+	EventMessagePartRemoved = "message.part.removed"
+	EventSessionStatus      = "session.status"
+	EventSessionUpdated     = "session.updated"
+	EventSessionIdle        = "session.idle"
+	EventSessionError       = "session.error"
+	EventSessionDeleted     = "session.deleted"
+	EventTodoUpdated        = "todo.updated"
+	EventFileEdited         = "file.edited"
+	EventFileWatcherUpdated = "file.watcher.updated"
+)
+
+type Event interface {
+	EventType() string
+}
+
+type ServerConnectedEvent struct{}
+
+func (ServerConnectedEvent) EventType() string { return EventServerConnected }
+
+type MessageUpdatedEvent struct {
+	Info Message `json:"info"`
+}
+
+func (MessageUpdatedEvent) EventType() string { return EventMessageUpdated }
+
+type MessagePartUpdatedEvent struct {
+	Part  Part   `json:"-"`
+	Delta string `json:"delta,omitempty"`
+}
+
+func (MessagePartUpdatedEvent) EventType() string { return EventMessagePartUpdated }
+
+func (e *MessagePartUpdatedEvent) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Part  json.RawMessage `json:"part"`
+		Delta string          `json:"delta"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	part, err := decodePart(raw.Part)
+	if err != nil {
+		return err
+	}
+	e.Part = part
+	e.Delta = raw.Delta
+	return nil
+}
+
+type MessageRemovedEvent struct {
+	SessionID string `json:"sessionID"`
+	MessageID string `json:"messageID"`
+}
+
+func (MessageRemovedEvent) EventType() string { return EventMessageRemoved }
+
+type MessagePartRemovedEvent struct {
+	SessionID string `json:"sessionID"`
+	MessageID string `json:"messageID"`
+	PartID    string `json:"partID"`
+}
+
+func (MessagePartRemovedEvent) EventType() string { return EventMessagePartRemoved }
+
+// SessionStatus.Type values.
+const (
+	SessionStatusIdle  = "idle"
+	SessionStatusBusy  = "busy"
+	SessionStatusRetry = "retry"
+)
+
+type SessionStatus struct {
+	// Type is SessionStatusIdle, SessionStatusBusy or SessionStatusRetry.
+	Type    string `json:"type"`
+	Attempt int    `json:"attempt,omitempty"`
+	Message string `json:"message,omitempty"`
+	Next    int64  `json:"next,omitempty"`
+}
+
+type SessionStatusEvent struct {
+	SessionID string        `json:"sessionID"`
+	Status    SessionStatus `json:"status"`
+}
+
+func (SessionStatusEvent) EventType() string { return EventSessionStatus }
+
+type SessionUpdatedEvent struct {
+	Info Session `json:"info"`
+}
+
+func (SessionUpdatedEvent) EventType() string { return EventSessionUpdated }
+
+type SessionIdleEvent struct {
+	SessionID string `json:"sessionID"`
+}
+
+func (SessionIdleEvent) EventType() string { return EventSessionIdle }
+
+type SessionErrorEvent struct {
+	SessionID string        `json:"sessionID,omitempty"`
+	Error     *MessageError `json:"error,omitempty"`
+}
+
+func (SessionErrorEvent) EventType() string { return EventSessionError }
+
+type SessionDeletedEvent struct {
+	Info Session `json:"info"`
+}
+
+func (SessionDeletedEvent) EventType() string { return EventSessionDeleted }
+
+type FileEditedEvent struct {
+	File string `json:"file"`
+}
+
+func (FileEditedEvent) EventType() string { return EventFileEdited }
+
+type FileWatcherUpdatedEvent struct {
+	File string `json:"file"`
+	// Event is one of "add", "change" or "unlink".
+	Event string `json:"event"`
+}
+
+func (FileWatcherUpdatedEvent) EventType() string { return EventFileWatcherUpdated }
+
+type UnknownEvent struct {
+	Type       string         `json:"type"`
+	Properties map[string]any `json:"properties"`
+}
+
+func (e UnknownEvent) EventType() string { return e.Type }
+
+var (
+	eventTypesMu sync.RWMutex
+	eventTypes   = map[string]func() Event{
+		EventServerConnected:    func() Event { return &ServerConnectedEvent{} },
+		EventMessageUpdated:     func() Event { return &MessageUpdatedEvent{} },
+		EventMessagePartUpdated: func() Event { return &MessagePartUpdatedEvent{} },
+		EventMessageRemoved:     func() Event { return &MessageRemovedEvent{} },
+		EventMessagePartRemoved: func() Event { return &MessagePartRemovedEvent{} },
+		EventSessionStatus:      func() Event { return &SessionStatusEvent{} },
+		EventSessionUpdated:     func() Event { return &SessionUpdatedEvent{} },
+		EventSessionIdle:        func() Event { return &SessionIdleEvent{} },
+		EventSessionError:       func() Event { return &SessionErrorEvent{} },
+		EventSessionDeleted:     func() Event { return &SessionDeletedEvent{} },
+		EventTodoUpdated:        func() Event { return &TodoUpdatedEvent{} },
+		EventFileEdited:         func() Event { return &FileEditedEvent{} },
+		EventFileWatcherUpdated: func() Event { return &FileWatcherUpdatedEvent{} },
+	}
+)
+
+// RegisterEventType makes ParseEvent decode the properties of events named
+// name into the value returned by factory, which must be a pointer.
+// Registering a built-in name replaces its decoder.
+func RegisterEventType(name string, factory func() Event) {
+	eventTypesMu.Lock()
+	defer eventTypesMu.Unlock()
+	eventTypes[name] = factory
+}
+
+func ParseEvent(data []byte) (Event, error) {
+	var envelope struct {
+		Type       string          `json:"type"`
+		Properties json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+
+	eventTypesMu.RLock()
+	factory, ok := eventTypes[envelope.Type]
+	eventTypesMu.RUnlock()
+
+	var event Event
+	if ok {
+		event = factory()
+	} else {
+		event = &UnknownEvent{Type: envelope.Type}
+	}
+	if len(envelope.Properties) > 0 {
+		target := any(event)
+		if unknown, ok := event.(*UnknownEvent); ok {
+			target = &unknown.Properties
+		}
+		if err := json.Unmarshal(envelope.Properties, target); err != nil {
+			return nil, fmt.Errorf("failed to decode %s event: %w", envelope.Type, err)
+		}
+	}
+	// Consumers rely on every part event carrying its part
+	if e, ok := event.(*MessagePartUpdatedEvent); ok && e.Part == nil {
+		return nil, fmt.Errorf("failed to decode %s event: missing part", envelope.Type)
+	}
+	return event, nil
+}
+
+func eventSessionID(event Event) string {
+	switch e := event.(type) {
+	case *MessageUpdatedEvent:
+		return e.Info.SessionID
+	case *MessagePartUpdatedEvent:
+		if e.Part != nil {
+			return e.Part.PartSessionID()
+		}
+	case *MessageRemovedEvent:
+		return e.SessionID
+	case *MessagePartRemovedEvent:
+		return e.SessionID
+	case *SessionStatusEvent:
+		return e.SessionID
+	case *SessionUpdatedEvent:
+		return e.Info.ID
+	case *SessionIdleEvent:
+		return e.SessionID
+	case *SessionErrorEvent:
+		return e.SessionID
+	case *SessionDeletedEvent:
+		return e.Info.ID
+	case *TodoUpdatedEvent:
+		return e.SessionID
+	case *UnknownEvent:
+		if id, ok := e.Properties["sessionID"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package opencode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvent(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"type":"message.part.updated","properties":{"part":{"id":"prt_1","type":"text","text":"hi"},"delta":"hi"}}`))
+	require.NoError(t, err)
+	partEvent, ok := event.(*MessagePartUpdatedEvent)
+	require.True(t, ok)
+	assert.Equal(t, "hi", partEvent.Delta)
+	assert.Equal(t, "hi", partEvent.Part.(*TextPart).Text)
+
+	event, err = ParseEvent([]byte(`{"type":"session.status","properties":{"sessionID":"ses_1","status":{"type":"busy"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &SessionStatusEvent{SessionID: "ses_1", Status: SessionStatus{Type: "busy"}}, event)
+}
+
+func TestParseEventMissingPart(t *testing.T) {
+	_, err := ParseEvent([]byte(`{"type":"message.part.updated"}`))
+	assert.ErrorContains(t, err, "missing part")
+	_, err = ParseEvent([]byte(`{"type":"message.part.updated","properties":{}}`))
+	assert.Error(t, err)
+}
+
+func TestParseEventRemoved(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &MessageRemovedEvent{SessionID: "ses_1", MessageID: "msg_1"}, event)
+
+	event, err = ParseEvent([]byte(`{"type":"message.part.removed","properties":{"sessionID":"ses_1","messageID":"msg_1","partID":"prt_1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &MessagePartRemovedEvent{SessionID: "ses_1", MessageID: "msg_1", PartID: "prt_1"}, event)
+}
+
+func TestParseEventUnknown(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"type":"lsp.updated","properties":{"foo":"bar"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "lsp.updated", event.EventType())
+	assert.Equal(t, map[string]any{"foo": "bar"}, event.(*UnknownEvent).Properties)
+}
+
+func TestParseEventMalformed(t *testing.T) {
+	_, err := ParseEvent([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestParseSessionLifecycleEvents(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"type":"session.idle","properties":{"sessionID":"ses_1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &SessionIdleEvent{SessionID: "ses_1"}, event)
+
+	event, err = ParseEvent([]byte(`{"type":"session.deleted","properties":{"info":{"id":"ses_1"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "ses_1", event.(*SessionDeletedEvent).Info.ID)
+
+	event, err = ParseEvent([]byte(`{"type":"session.error","properties":{"sessionID":"ses_1","error":{"name":"ProviderAuthError","data":{"providerID":"anthropic","message":"invalid key"}}}}`))
+	require.NoError(t, err)
+	sessionErr := event.(*SessionErrorEvent)
+	assert.Equal(t, "ses_1", sessionErr.SessionID)
+	assert.Equal(t, "anthropic", sessionErr.Error.Data.ProviderID)
+	assert.ErrorIs(t, sessionErr.Error, ErrProviderAuth)
+	assert.NotErrorIs(t, sessionErr.Error, ErrAborted)
+	assert.EqualError(t, sessionErr.Error, "ProviderAuthError: invalid key")
+}
+
+func TestParseFileEvents(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"type":"file.edited","properties":{"file":"/work/main.go"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &FileEditedEvent{File: "/work/main.go"}, event)
+
+	event, err = ParseEvent([]byte(`{"type":"file.watcher.updated","properties":{"file":"/work/old.go","event":"unlink"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, &FileWatcherUpdatedEvent{File: "/work/old.go", Event: "unlink"}, event)
+}
+
+type lspDiagnosticsEvent struct {
+	ServerID string `json:"serverID"`
+	Path     string `json:"path"`
+}
+
+func (lspDiagnosticsEvent) EventType() string { return "lsp.client.diagnostics" }
+
+func TestRegisterEventType(t *testing.T) {
+	data := []byte(`{"type":"lsp.client.diagnostics","properties":{"serverID":"gopls","path":"/work/main.go"}}`)
+	event, err := ParseEvent(data)
+	require.NoError(t, err)
+	assert.IsType(t, &UnknownEvent{}, event)
+
+	RegisterEventType("lsp.client.diagnostics", func() Event { return &lspDiagnosticsEvent{} })
+	t.Cleanup(func() {
+		eventTypesMu.Lock()
+		delete(eventTypes, "lsp.client.diagnostics")
+		eventTypesMu.Unlock()
+	})
+
+	event, err = ParseEvent(data)
+	require.NoError(t, err)
+	assert.Equal(t, &lspDiagnosticsEvent{ServerID: "gopls", Path: "/work/main.go"}, event)
+}
+
+var benchmarkEvents = map[string][]byte{
+	"part":    []byte(`{"type":"message.part.updated","properties":{"part":{"id":"prt_1","sessionID":"ses_1","messageID":"msg_1","type":"text","text":"Hello, world. This is a streamed answer that keeps growing."},"delta":"growing."}}`),
+	"message": []byte(`{"type":"message.updated","properties":{"info":{"id":"msg_1","sessionID":"ses_1","role":"assistant","time":{"created":1},"modelID":"m","providerID":"p"}}}`),
+	"status":  []byte(`{"type":"session.status","properties":{"sessionID":"ses_1","status":{"type":"busy"}}}`),
+	"unknown": []byte(`{"type":"lsp.updated","properties":{"serverID":"gopls"}}`),
+}
+
+func BenchmarkParseEvent(b *testing.B) {
+	for name, data := range benchmarkEvents {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := ParseEvent(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestEventTypeConstants(t *testing.T) {
+	eventTypesMu.RLock()
+	defer eventTypesMu.RUnlock()
+	for name, factory := range eventTypes {
+		assert.Equal(t, name, factory().EventType())
+	}
+	assert.Equal(t, EventMessageUpdated, (&MessageUpdatedEvent{}).EventType())
+}
@@ -0,0 +1,93 @@
+package opencode
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ExitState struct {
+	PID      int
+	Code     int
+	ExitedAt time.Time
+	// Stderr holds the last few kilobytes the process wrote to stderr.
+	Stderr string
+	Err    error
+}
+
+// ExitError is delivered on Done when opencode exits with a non-zero status
+// or is killed.
+type ExitError struct {
+	ExitState
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("opencode exited with code %d", e.Code)
+	if e.Code < 0 && e.Err != nil {
+		msg = fmt.Sprintf("opencode exited: %v", e.Err)
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+func newExitState(pid int, err error, stderr string) *ExitState {
+	state := &ExitState{PID: pid, ExitedAt: time.Now(), Stderr: stderr, Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		state.Code = exitErr.ExitCode()
+	} else if err != nil {
+		state.Code = -1
+	}
+	return state
+}
+
+// Done returns a channel that receives the exit error of the running
+// process, nil for a clean exit, once it exits. The channel is closed after
+// the single value has been received, so use ExitState to inspect the exit
+// from more than one place.
+func (oc *OpenCode) Done() <-chan error {
+	oc.exitMu.Lock()
+	defer oc.exitMu.Unlock()
+	return oc.done
+}
+
+// ExitState describes how the last process exited, or returns nil while it
+// is running or was never started.
+func (oc *OpenCode) ExitState() *ExitState {
+	oc.exitMu.Lock()
+	defer oc.exitMu.Unlock()
+	return oc.exitState
+}
+
+type tailBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.size:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
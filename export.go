@@ -0,0 +1,218 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+type ExportFormat string
+
+const (
+	ExportMarkdown ExportFormat = "markdown"
+	ExportHTML     ExportFormat = "html"
+	// ExportJSON writes the session and its messages as returned by the
+	// server, with stable indentation.
+	ExportJSON ExportFormat = "json"
+)
+
+// ExportSession writes a session's full conversation, including reasoning,
+// tool calls and their diffs, to w.
+func (oc *OpenCode) ExportSession(ctx context.Context, sessionID string, format ExportFormat, w io.Writer) error {
+	session, err := oc.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	messages, err := oc.ListMessages(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(struct {
+			Session  *Session           `json:"session"`
+			Messages []MessageWithParts `json:"messages"`
+		}{session, messages})
+	case ExportMarkdown:
+		return exportMarkdown(w, newExport(session, messages))
+	case ExportHTML:
+		return exportHTMLTemplate.Execute(w, newExport(session, messages))
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+// export is the conversation prepared for the Markdown and HTML renderers.
+type export struct {
+	Title    string
+	Session  *Session
+	Created  string
+	Messages []exportMessage
+}
+
+type exportMessage struct {
+	Role string
+	// Meta is the model, cost and tokens of assistant messages.
+	Meta   string
+	Blocks []exportBlock
+}
+
+type exportBlock struct {
+	// Kind is text, reasoning, tool, code, diff, file or error.
+	Kind  string
+	Title string
+	Body  string
+}
+
+func newExport(session *Session, messages []MessageWithParts) export {
+	e := export{
+		Title:   session.Title,
+		Session: session,
+		Created: time.UnixMilli(session.Time.Created).UTC().Format(time.RFC3339),
+	}
+	if e.Title == "" {
+		e.Title = session.ID
+	}
+	for i := range messages {
+		m := &messages[i]
+		out := exportMessage{Role: "User"}
+		if m.Info.Role == RoleAssistant {
+			out.Role = "Assistant"
+			cost, tokens := m.Usage()
+			out.Meta = fmt.Sprintf("%s/%s · $%.4f · %d tokens", m.Info.ProviderID, m.Info.ModelID, cost, tokens.Total())
+		}
+		for _, part := range m.Parts {
+			out.Blocks = append(out.Blocks, exportBlocks(part)...)
+		}
+		if m.Info.Error != nil {
+			out.Blocks = append(out.Blocks, exportBlock{Kind: "error", Body: m.Info.Error.Error()})
+		}
+		e.Messages = append(e.Messages, out)
+	}
+	return e
+}
+
+func exportBlocks(part Part) []exportBlock {
+	switch p := part.(type) {
+	case *TextPart:
+		if p.Synthetic || p.Text == "" {
+			return nil
+		}
+		return []exportBlock{{Kind: "text", Body: p.Text}}
+	case *ReasoningPart:
+		if p.Text == "" {
+			return nil
+		}
+		return []exportBlock{{Kind: "reasoning", Title: "Reasoning", Body: p.Text}}
+	case *ToolPart:
+		title := "Tool: " + p.Tool
+		if p.Title != "" {
+			title += " - " + p.Title
+		}
+		input, _ := json.MarshalIndent(p.Input, "", "  ")
+		blocks := []exportBlock{{Kind: "tool", Title: title, Body: string(input)}}
+		if diff, _ := p.Metadata["diff"].(string); diff != "" {
+			blocks = append(blocks, exportBlock{Kind: "diff", Title: "Diff", Body: diff})
+		} else if p.Output != "" {
+			blocks = append(blocks, exportBlock{Kind: "code", Title: "Output", Body: p.Output})
+		}
+		if p.Error != "" {
+			blocks = append(blocks, exportBlock{Kind: "error", Body: p.Error})
+		}
+		return blocks
+	case *FilePart:
+		return []exportBlock{{Kind: "file", Body: fmt.Sprintf("%s (%s)", p.Filename, p.Mime)}}
+	case *PatchPart:
+		return []exportBlock{{Kind: "file", Body: fmt.Sprintf("Patch %s: %s", p.Hash, strings.Join(p.Files, ", "))}}
+	}
+	return nil
+}
+
+func exportMarkdown(w io.Writer, e export) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\nSession `%s` · created %s", e.Title, e.Session.ID, e.Created)
+	if e.Session.Directory != "" {
+		fmt.Fprintf(&sb, " · `%s`", e.Session.Directory)
+	}
+	sb.WriteString("\n")
+	for _, m := range e.Messages {
+		fmt.Fprintf(&sb, "\n## %s\n\n", m.Role)
+		if m.Meta != "" {
+			fmt.Fprintf(&sb, "_%s_\n\n", m.Meta)
+		}
+		for _, b := range m.Blocks {
+			switch b.Kind {
+			case "text":
+				sb.WriteString(strings.TrimRight(b.Body, "\n") + "\n\n")
+			case "reasoning":
+				sb.WriteString("> **Reasoning**\n>\n> " + strings.ReplaceAll(strings.TrimRight(b.Body, "\n"), "\n", "\n> ") + "\n\n")
+			case "tool":
+				fmt.Fprintf(&sb, "**%s**\n\n%s\n\n", b.Title, fenced("json", b.Body))
+			case "code", "diff":
+				lang := ""
+				if b.Kind == "diff" {
+					lang = "diff"
+				}
+				fmt.Fprintf(&sb, "%s:\n\n%s\n\n", b.Title, fenced(lang, b.Body))
+			case "file":
+				fmt.Fprintf(&sb, "📎 %s\n\n", b.Body)
+			case "error":
+				fmt.Fprintf(&sb, "**Error:** %s\n\n", b.Body)
+			}
+		}
+	}
+	_, err := io.WriteString(w, strings.TrimRight(sb.String(), "\n")+"\n")
+	return err
+}
+
+// fenced wraps body in a code fence longer than any backtick run inside it.
+func fenced(lang, body string) string {
+	fence := "```"
+	for strings.Contains(body, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + strings.TrimRight(body, "\n") + "\n" + fence
+}
+
+var exportHTMLTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; color: #1f2328; line-height: 1.5; }
+header p, .meta { color: #59636e; font-size: 0.875rem; }
+section { border: 1px solid #d1d9e0; border-radius: 6px; padding: 0.75rem 1rem; margin: 1rem 0; }
+section.user { background: #f6f8fa; }
+h2 { font-size: 1rem; margin: 0 0 0.5rem; }
+.text { white-space: pre-wrap; }
+details { margin: 0.5rem 0; color: #59636e; }
+pre { background: #f6f8fa; border-radius: 6px; padding: 0.75rem; overflow-x: auto; font-size: 0.8125rem; }
+.error { color: #d1242f; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Session <code>{{.Session.ID}}</code> · created {{.Created}}{{with .Session.Directory}} · <code>{{.}}</code>{{end}}</p>
+</header>
+{{range .Messages}}<section class="{{if eq .Role "User"}}user{{else}}assistant{{end}}">
+<h2>{{.Role}}</h2>
+{{with .Meta}}<p class="meta">{{.}}</p>
+{{end}}{{range .Blocks}}{{if eq .Kind "text"}}<div class="text">{{.Body}}</div>
+{{else if eq .Kind "reasoning"}}<details><summary>{{.Title}}</summary><div class="text">{{.Body}}</div></details>
+{{else if eq .Kind "error"}}<p class="error"><strong>Error:</strong> {{.Body}}</p>
+{{else if eq .Kind "file"}}<p>📎 {{.Body}}</p>
+{{else if eq .Kind "tool"}}<details open><summary><strong>{{.Title}}</strong></summary><pre>{{.Body}}</pre></details>
+{{else}}<details><summary>{{.Title}}</summary><pre class="{{.Kind}}">{{.Body}}</pre></details>
+{{end}}{{end}}</section>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,81 @@
+package opencode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportServer(t *testing.T) *OpenCode {
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/ses_1":
+			w.Write([]byte(`{"id":"ses_1","title":"Fix <the> bug","directory":"/work","time":{"created":1700000000000}}`))
+		case "/session/ses_1/message":
+			w.Write([]byte(`[
+				{"info":{"id":"msg_1","sessionID":"ses_1","role":"user"},"parts":[{"id":"prt_1","type":"text","text":"Fix it"}]},
+				{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant","providerID":"anthropic","modelID":"claude"},"parts":[
+					{"id":"prt_2","type":"reasoning","text":"Look at main.go"},
+					{"id":"prt_3","type":"tool","tool":"edit","state":{"status":"completed","title":"main.go","input":{"filePath":"main.go"},"metadata":{"diff":"--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-bug\n+fix\n"}}},
+					{"id":"prt_4","type":"text","text":"Done:\n` + "```" + `go\nfix\n` + "```" + `"},
+					{"id":"prt_5","type":"step-finish","cost":0.01,"tokens":{"input":10,"output":5,"reasoning":0,"cache":{"read":0,"write":0}}},
+					{"id":"prt_6","type":"agent","name":"review"}
+				]}
+			]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func TestExportSessionMarkdown(t *testing.T) {
+	oc := exportServer(t)
+	var buf bytes.Buffer
+	require.NoError(t, oc.ExportSession(context.Background(), "ses_1", ExportMarkdown, &buf))
+	out := buf.String()
+	for _, want := range []string{
+		"# Fix <the> bug\n\nSession `ses_1` · created 2023-11-14T22:13:20Z · `/work`\n",
+		"## User\n\nFix it\n",
+		"## Assistant\n\n_anthropic/claude · $0.0100 · 15 tokens_\n",
+		"> **Reasoning**\n>\n> Look at main.go\n",
+		"**Tool: edit - main.go**\n\n```json\n{\n  \"filePath\": \"main.go\"\n}\n```\n",
+		"Diff:\n\n```diff\n--- a/main.go\n",
+		"Done:\n```go\nfix\n```\n",
+	} {
+		assert.Contains(t, out, want)
+	}
+}
+
+func TestExportSessionHTML(t *testing.T) {
+	oc := exportServer(t)
+	var buf bytes.Buffer
+	require.NoError(t, oc.ExportSession(context.Background(), "ses_1", ExportHTML, &buf))
+	out := buf.String()
+	assert.Contains(t, out, "<title>Fix &lt;the&gt; bug</title>")
+	assert.Contains(t, out, `<pre class="diff">--- a/main.go`)
+	assert.Contains(t, out, `<section class="assistant">`)
+}
+
+func TestExportSessionJSON(t *testing.T) {
+	oc := exportServer(t)
+	var buf bytes.Buffer
+	require.NoError(t, oc.ExportSession(context.Background(), "ses_1", ExportJSON, &buf))
+
+	var decoded struct {
+		Session  Session            `json:"session"`
+		Messages []MessageWithParts `json:"messages"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "ses_1", decoded.Session.ID)
+	require.Len(t, decoded.Messages, 2)
+	parts := decoded.Messages[1].Parts
+	assert.Equal(t, "main.go", parts[1].(*ToolPart).Title)
+	assert.JSONEq(t, `{"id":"prt_6","type":"agent","name":"review"}`, string(parts[4].(*UnknownPart).Raw))
+
+	assert.ErrorContains(t, oc.ExportSession(context.Background(), "ses_1", "pdf", &buf), `unknown export format "pdf"`)
+}
@@ -0,0 +1,289 @@
+// Package fanout sends the same prompt to several sessions, each with its own
+// model or agent, and reduces their answers to one.
+package fanout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-shift/opencode"
+)
+
+// Target is one of the sessions a prompt is sent to. Zero fields use the
+// server's defaults.
+type Target struct {
+	// Name identifies the target in results. Defaults to the model as
+	// "provider/model", the agent, or the target's index.
+	Name    string
+	Model   opencode.Model
+	Agent   string
+	Options []opencode.MessageOption
+}
+
+type Result struct {
+	Target Target
+	// Index is the position of the target in Runner.Targets.
+	Index     int
+	Prompt    string
+	SessionID string
+	Message   *opencode.MessageWithParts
+	Text      string
+	Err       error
+	Duration  time.Duration
+}
+
+type Runner struct {
+	Client  opencode.Client
+	Targets []Target
+	// Keep leaves the target sessions on the server. By default each one is
+	// deleted once its result has been collected.
+	Keep bool
+	// Logger defaults to the client's logger.
+	Logger *slog.Logger
+}
+
+// clientLogger returns the logger of client when it has one, so fanout logs
+// are redacted like the client's own.
+func clientLogger(client opencode.Client) *slog.Logger {
+	if oc, ok := client.(*opencode.OpenCode); ok {
+		return oc.Logger()
+	}
+	return slog.Default()
+}
+
+func (r *Runner) logger() *slog.Logger {
+	if r.Logger != nil {
+		return r.Logger
+	}
+	return clientLogger(r.Client)
+}
+
+// deleteSession removes a session even when ctx is already done.
+func deleteSession(ctx context.Context, client opencode.Client, log *slog.Logger, sessionID string) {
+	if err := client.DeleteSession(context.WithoutCancel(ctx), sessionID); err != nil {
+		log.Warn("Failed to delete fanout session", "session", sessionID, "err", err)
+	}
+}
+
+func (r *Runner) targets() []Target {
+	targets := make([]Target, len(r.Targets))
+	for i, target := range r.Targets {
+		if target.Name == "" {
+			switch {
+			case target.Model.ModelID != "":
+				target.Name = target.Model.ProviderID + "/" + target.Model.ModelID
+			case target.Agent != "":
+				target.Name = target.Agent
+			default:
+				target.Name = fmt.Sprint(i)
+			}
+		}
+		targets[i] = target
+	}
+	return targets
+}
+
+// Stream creates a session per target, sends prompt to all of them in
+// parallel and delivers each result as soon as it has finished. The channel
+// is closed once all targets have finished. Sessions still generating when
+// ctx is done are aborted, and all of them are deleted unless Keep is set.
+func (r *Runner) Stream(ctx context.Context, prompt string) <-chan Result {
+	targets := r.targets()
+	results := make(chan Result, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := r.run(ctx, target, prompt)
+			result.Index = i
+			results <- result
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+func (r *Runner) run(ctx context.Context, target Target, prompt string) Result {
+	result := Result{Target: target, Prompt: prompt}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	session, err := r.Client.CreateSession(ctx, opencode.SessionCreate{Title: "fanout " + target.Name})
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.SessionID = session.ID
+	log := r.logger()
+	if !r.Keep {
+		defer deleteSession(ctx, r.Client, log, session.ID)
+	}
+
+	opts := append([]opencode.MessageOption{}, target.Options...)
+	if target.Model.ModelID != "" {
+		opts = append(opts, opencode.WithModel(target.Model))
+	}
+	if target.Agent != "" {
+		opts = append(opts, opencode.WithAgent(target.Agent))
+	}
+	message, err := r.Client.SendMessage(ctx, session.ID, append(opts, opencode.Text(prompt))...)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The server keeps generating after the request is cancelled
+			if err := r.Client.AbortSession(context.WithoutCancel(ctx), session.ID); err != nil {
+				log.Warn("Failed to abort fanout session", "target", target.Name, "session", session.ID, "err", err)
+			}
+		}
+		result.Err = err
+		return result
+	}
+	result.Message = message
+	result.Text = message.Text()
+	if message.Info.Error != nil {
+		result.Err = message.Info.Error
+	}
+	log.Debug("Fanout target finished", "target", target.Name, "session", session.ID, "err", result.Err)
+	return result
+}
+
+// Run sends prompt to every target and returns the results in the order of
+// Targets.
+func (r *Runner) Run(ctx context.Context, prompt string) []Result {
+	results := make([]Result, len(r.Targets))
+	for result := range r.Stream(ctx, prompt) {
+		results[result.Index] = result
+	}
+	return results
+}
+
+// Reducer picks one result out of the stream of results, which is closed
+// after the last one.
+type Reducer func(ctx context.Context, results <-chan Result) (Result, error)
+
+// Reduce sends prompt to every target and picks a result with reduce. The
+// targets still running when reduce returns are aborted.
+func (r *Runner) Reduce(ctx context.Context, prompt string, reduce Reducer) (Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return reduce(ctx, r.Stream(ctx, prompt))
+}
+
+// failed joins the errors of results that did not succeed.
+func failed(results []Result) error {
+	errs := []error{errors.New("no target succeeded")}
+	for _, result := range results {
+		errs = append(errs, fmt.Errorf("%s: %w", result.Target.Name, result.Err))
+	}
+	return errors.Join(errs...)
+}
+
+// FirstSuccess picks the first result without an error.
+func FirstSuccess() Reducer {
+	return func(ctx context.Context, results <-chan Result) (Result, error) {
+		var failures []Result
+		for result := range results {
+			if result.Err == nil {
+				return result, nil
+			}
+			failures = append(failures, result)
+		}
+		return Result{}, failed(failures)
+	}
+}
+
+// MajorityVote picks the answer most targets agree on, comparing answers by
+// normalize, which defaults to ignoring case and surrounding whitespace. Ties
+// go to the answer that was complete first.
+func MajorityVote(normalize func(string) string) Reducer {
+	if normalize == nil {
+		normalize = func(s string) string { return strings.ToLower(strings.TrimSpace(s)) }
+	}
+	return func(ctx context.Context, results <-chan Result) (Result, error) {
+		var failures, order []Result
+		votes := make(map[string]int)
+		for result := range results {
+			if result.Err != nil {
+				failures = append(failures, result)
+				continue
+			}
+			key := normalize(result.Text)
+			if votes[key] == 0 {
+				order = append(order, result)
+			}
+			votes[key]++
+		}
+		if len(order) == 0 {
+			return Result{}, failed(failures)
+		}
+		best := order[0]
+		for _, result := range order[1:] {
+			if votes[normalize(result.Text)] > votes[normalize(best.Text)] {
+				best = result
+			}
+		}
+		return best, nil
+	}
+}
+
+// Judge waits for all targets and asks the client's default model, in a new
+// session, which of the successful answers best satisfies instructions. The
+// answers are numbered in the order of Runner.Targets. The judge session is
+// deleted afterwards.
+func Judge(client opencode.Client, instructions string) Reducer {
+	return func(ctx context.Context, results <-chan Result) (Result, error) {
+		var failures, candidates []Result
+		for result := range results {
+			if result.Err != nil {
+				failures = append(failures, result)
+			} else {
+				candidates = append(candidates, result)
+			}
+		}
+		slices.SortFunc(candidates, func(a, b Result) int { return a.Index - b.Index })
+		switch len(candidates) {
+		case 0:
+			return Result{}, failed(failures)
+		case 1:
+			return candidates[0], nil
+		}
+
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "%s\n\nThe task was:\n\n%s\n", instructions, candidates[0].Prompt)
+		for i, candidate := range candidates {
+			fmt.Fprintf(&prompt, "\n<answer number=\"%d\">\n%s\n</answer>\n", i+1, candidate.Text)
+		}
+		prompt.WriteString("\nReply with the number of the best answer.")
+
+		session, err := client.CreateSession(ctx, opencode.SessionCreate{Title: "fanout judge"})
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to create judge session: %w", err)
+		}
+		defer deleteSession(ctx, client, clientLogger(client), session.ID)
+		schema := map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"best": map[string]any{"type": "integer", "minimum": 1, "maximum": len(candidates)}},
+			"required":             []string{"best"},
+			"additionalProperties": false,
+		}
+		verdict, err := opencode.AskJSON[struct {
+			Best int `json:"best"`
+		}](ctx, client, session.ID, prompt.String(), schema)
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to judge answers: %w", err)
+		}
+		if verdict.Best < 1 || verdict.Best > len(candidates) {
+			return Result{}, fmt.Errorf("judge picked answer %d of %d", verdict.Best, len(candidates))
+		}
+		return candidates[verdict.Best-1], nil
+	}
+}
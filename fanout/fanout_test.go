@@ -0,0 +1,127 @@
+package fanout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ai-shift/opencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer answers prompts with the text answers[modelID]. Models without an
+// answer hang until the request is cancelled, and "broken" fails. It returns
+// the sessions aborted and the number of sessions left on the server.
+func newServer(t *testing.T, answers map[string]string) (*opencode.OpenCode, *sync.Map, *atomic.Int32) {
+	var sessions, live atomic.Int32
+	var aborted sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session" {
+			live.Add(1)
+			fmt.Fprintf(w, `{"id":"ses_%d"}`, sessions.Add(1))
+			return
+		}
+		if r.Method == http.MethodDelete {
+			live.Add(-1)
+			w.Write([]byte(`true`))
+			return
+		}
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/session/"), "/abort"); ok {
+			aborted.Store(id, true)
+			w.Write([]byte(`true`))
+			return
+		}
+		var req struct {
+			Model struct {
+				ModelID string `json:"modelID"`
+			} `json:"model"`
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		answer, ok := answers[req.Model.ModelID]
+		switch {
+		case req.Model.ModelID == "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case req.Model.ModelID == "" && strings.Contains(req.Parts[0].Text, "Pick the shortest"):
+			answer = `{"best": 2}`
+		case !ok:
+			<-r.Context().Done()
+			return
+		}
+		text, _ := json.Marshal(answer)
+		fmt.Fprintf(w, `{"info":{"id":"msg","role":"assistant"},"parts":[{"id":"prt","type":"text","text":%s}]}`, text)
+	}))
+	t.Cleanup(srv.Close)
+	return opencode.New(opencode.Config{Addr: strings.TrimPrefix(srv.URL, "http://")}), &aborted, &live
+}
+
+func model(id string) Target {
+	return Target{Model: opencode.Model{ProviderID: "p", ModelID: id}}
+}
+
+func TestRun(t *testing.T) {
+	oc, _, live := newServer(t, map[string]string{"a": "four", "b": "4"})
+	runner := &Runner{Client: oc, Targets: []Target{model("a"), model("broken"), {Name: "named", Model: opencode.Model{ModelID: "b"}}}}
+
+	results := runner.Run(context.Background(), "2+2?")
+	require.Len(t, results, 3)
+	assert.Equal(t, "p/a", results[0].Target.Name)
+	assert.Equal(t, "four", results[0].Text)
+	assert.NotEmpty(t, results[0].SessionID)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, "named", results[2].Target.Name)
+	assert.Equal(t, 2, results[2].Index)
+	assert.Zero(t, live.Load())
+
+	runner.Keep = true
+	runner.Run(context.Background(), "2+2?")
+	assert.EqualValues(t, 3, live.Load())
+}
+
+func TestFirstSuccessAbortsTheRest(t *testing.T) {
+	oc, aborted, live := newServer(t, map[string]string{"fast": "done"})
+	runner := &Runner{Client: oc, Targets: []Target{model("broken"), model("slow"), model("fast")}}
+
+	result, err := runner.Reduce(context.Background(), "go", FirstSuccess())
+	require.NoError(t, err)
+	assert.Equal(t, "p/fast", result.Target.Name)
+	assert.Eventually(t, func() bool {
+		n := 0
+		aborted.Range(func(_, _ any) bool { n++; return true })
+		return n == 1 && live.Load() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestMajorityVote(t *testing.T) {
+	oc, _, _ := newServer(t, map[string]string{"a": "Paris", "b": "Lyon", "c": " paris\n"})
+	runner := &Runner{Client: oc, Targets: []Target{model("a"), model("b"), model("c"), model("broken")}}
+
+	result, err := runner.Reduce(context.Background(), "capital?", MajorityVote(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "paris", strings.ToLower(strings.TrimSpace(result.Text)))
+
+	runner.Targets = []Target{model("broken")}
+	_, err = runner.Reduce(context.Background(), "capital?", MajorityVote(nil))
+	assert.ErrorContains(t, err, "no target succeeded")
+}
+
+func TestJudge(t *testing.T) {
+	oc, _, live := newServer(t, map[string]string{"a": "a long answer", "b": "short"})
+	runner := &Runner{Client: oc, Targets: []Target{model("a"), model("b")}}
+
+	result, err := runner.Reduce(context.Background(), "explain", Judge(oc, "Pick the shortest answer."))
+	require.NoError(t, err)
+	assert.Equal(t, "short", result.Text)
+	assert.Zero(t, live.Load())
+}
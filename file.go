@@ -0,0 +1,57 @@
+package opencode
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type FileContent struct {
+	// Type is "text" or, on older servers, "raw" for the file as on disk and
+	// "patch" for a file with uncommitted changes.
+	Type    string `json:"type"`
+	Content string `json:"content"`
+	// Diff is the unified diff against the last commit for changed files.
+	Diff string `json:"diff,omitempty"`
+	// Encoding is "base64" for binary files such as images.
+	Encoding string `json:"encoding,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// Bytes returns the content, decoding base64 for binary files.
+func (f *FileContent) Bytes() ([]byte, error) {
+	if f.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(f.Content)
+	}
+	return []byte(f.Content), nil
+}
+
+// ReadFile returns a file as the server sees it. path is relative to the
+// project directory.
+func (oc *OpenCode) ReadFile(ctx context.Context, path string) (*FileContent, error) {
+	var content FileContent
+	if err := oc.do(ctx, http.MethodGet, "/file/content?path="+url.QueryEscape(path), nil, &content); err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	return &content, nil
+}
+
+type FileStatus struct {
+	Path    string `json:"path"`
+	Added   int    `json:"added"`
+	Removed int    `json:"removed"`
+	// Status is "added", "deleted" or "modified".
+	Status string `json:"status"`
+}
+
+// FileStatus returns the files changed in the project since the last commit,
+// with added and removed line counts.
+func (oc *OpenCode) FileStatus(ctx context.Context) ([]FileStatus, error) {
+	var files []FileStatus
+	if err := oc.do(ctx, http.MethodGet, "/file/status", nil, &files); err != nil {
+		return nil, fmt.Errorf("failed to get file status: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,55 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadFile(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/file/content", r.URL.Path)
+		switch r.URL.Query().Get("path") {
+		case "cmd/main file.go":
+			w.Write([]byte(`{"type":"patch","content":"package main\n","diff":"--- a/cmd/main file.go"}`))
+		case "logo.png":
+			w.Write([]byte(`{"type":"text","content":"iVBO","encoding":"base64","mimeType":"image/png"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	file, err := oc.ReadFile(context.Background(), "cmd/main file.go")
+	require.NoError(t, err)
+	assert.Equal(t, "patch", file.Type)
+	assert.Equal(t, "--- a/cmd/main file.go", file.Diff)
+	data, err := file.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n", string(data))
+
+	image, err := oc.ReadFile(context.Background(), "logo.png")
+	require.NoError(t, err)
+	data, err = image.Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x89, 'P', 'N'}, data)
+
+	_, err = oc.ReadFile(context.Background(), "missing.go")
+	assert.ErrorContains(t, err, "failed to read file missing.go")
+}
+
+func TestFileStatus(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/file/status", r.URL.Path)
+		w.Write([]byte(`[{"path":"main.go","added":3,"removed":1,"status":"modified"},{"path":"old.go","added":0,"removed":20,"status":"deleted"}]`))
+	})
+
+	files, err := oc.FileStatus(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []FileStatus{
+		{Path: "main.go", Added: 3, Removed: 1, Status: "modified"},
+		{Path: "old.go", Removed: 20, Status: "deleted"},
+	}, files)
+}
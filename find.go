@@ -0,0 +1,147 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// TextMatch is one line matched by FindText.
+type TextMatch struct {
+	Path string
+	// Line is the matched line including its newline.
+	Line       string
+	LineNumber int
+	// Offset is the byte offset of the line in the file.
+	Offset     int
+	Submatches []Submatch
+}
+
+// Submatch is a match within TextMatch.Line. Start and End are byte offsets
+// into the line.
+type Submatch struct {
+	Text  string
+	Start int
+	End   int
+}
+
+type ripgrepText struct {
+	Text string `json:"text"`
+}
+
+// UnmarshalJSON flattens ripgrep's {"text": ...} wrappers.
+func (m *TextMatch) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Path           ripgrepText `json:"path"`
+		Lines          ripgrepText `json:"lines"`
+		LineNumber     int         `json:"line_number"`
+		AbsoluteOffset int         `json:"absolute_offset"`
+		Submatches     []struct {
+			Match ripgrepText `json:"match"`
+			Start int         `json:"start"`
+			End   int         `json:"end"`
+		} `json:"submatches"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = TextMatch{Path: raw.Path.Text, Line: raw.Lines.Text, LineNumber: raw.LineNumber, Offset: raw.AbsoluteOffset}
+	for _, sub := range raw.Submatches {
+		m.Submatches = append(m.Submatches, Submatch{Text: sub.Match.Text, Start: sub.Start, End: sub.End})
+	}
+	return nil
+}
+
+type FindOptions struct {
+	// Limit caps the number of matches returned, zero for all.
+	Limit int
+}
+
+// FindText searches the project's files for a ripgrep regular expression.
+func (oc *OpenCode) FindText(ctx context.Context, pattern string, opts FindOptions) ([]TextMatch, error) {
+	var matches []TextMatch
+	if err := oc.do(ctx, http.MethodGet, "/find?pattern="+url.QueryEscape(pattern), nil, &matches); err != nil {
+		return nil, fmt.Errorf("failed to find %q: %w", pattern, err)
+	}
+	if opts.Limit > 0 && len(matches) > opts.Limit {
+		matches = matches[:opts.Limit]
+	}
+	return matches, nil
+}
+
+// FindFiles returns project file paths matching a fuzzy query.
+func (oc *OpenCode) FindFiles(ctx context.Context, query string) ([]string, error) {
+	var paths []string
+	if err := oc.do(ctx, http.MethodGet, "/find/file?query="+url.QueryEscape(query), nil, &paths); err != nil {
+		return nil, fmt.Errorf("failed to find files %q: %w", query, err)
+	}
+	return paths, nil
+}
+
+// SymbolKind is an LSP symbol kind.
+type SymbolKind int
+
+var symbolKinds = []string{
+	"file", "module", "namespace", "package", "class", "method", "property",
+	"field", "constructor", "enum", "interface", "function", "variable",
+	"constant", "string", "number", "boolean", "array", "object", "key", "null",
+	"enum member", "struct", "event", "operator", "type parameter",
+}
+
+func (k SymbolKind) String() string {
+	if k < 1 || int(k) > len(symbolKinds) {
+		return fmt.Sprintf("kind %d", int(k))
+	}
+	return symbolKinds[k-1]
+}
+
+type Position struct {
+	// Line and Character are 0-based.
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Symbol struct {
+	Name string
+	Kind SymbolKind
+	Path string
+	// Range covers the symbol's whole definition.
+	Range Range
+}
+
+// UnmarshalJSON turns the LSP location's file URI into a path.
+func (s *Symbol) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name     string     `json:"name"`
+		Kind     SymbolKind `json:"kind"`
+		Location struct {
+			URI   string `json:"uri"`
+			Range Range  `json:"range"`
+		} `json:"location"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	path := raw.Location.URI
+	if u, err := url.Parse(path); err == nil && u.Scheme == "file" {
+		path = u.Path
+	}
+	*s = Symbol{Name: raw.Name, Kind: raw.Kind, Path: path, Range: raw.Location.Range}
+	return nil
+}
+
+// FindSymbols searches the workspace symbols of the running language servers.
+func (oc *OpenCode) FindSymbols(ctx context.Context, query string) ([]Symbol, error) {
+	var symbols []Symbol
+	if err := oc.do(ctx, http.MethodGet, "/find/symbol?query="+url.QueryEscape(query), nil, &symbols); err != nil {
+		return nil, fmt.Errorf("failed to find symbols %q: %w", query, err)
+	}
+	return symbols, nil
+}
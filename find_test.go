@@ -0,0 +1,66 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindText(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/find", r.URL.Path)
+		assert.Equal(t, `func \w+Session`, r.URL.Query().Get("pattern"))
+		w.Write([]byte(`[
+			{"path":{"text":"session.go"},"lines":{"text":"func (oc *OpenCode) GetSession(\n"},"line_number":42,"absolute_offset":900,"submatches":[{"match":{"text":"func (oc *OpenCode) GetSession"},"start":0,"end":30}]},
+			{"path":{"text":"session.go"},"lines":{"text":"func sessionError(\n"},"line_number":80,"absolute_offset":1800,"submatches":[]}
+		]`))
+	})
+
+	matches, err := oc.FindText(context.Background(), `func \w+Session`, FindOptions{})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, TextMatch{
+		Path:       "session.go",
+		Line:       "func (oc *OpenCode) GetSession(\n",
+		LineNumber: 42,
+		Offset:     900,
+		Submatches: []Submatch{{Text: "func (oc *OpenCode) GetSession", Start: 0, End: 30}},
+	}, matches[0])
+
+	matches, err = oc.FindText(context.Background(), `func \w+Session`, FindOptions{Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
+
+func TestFindFiles(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/find/file", r.URL.Path)
+		assert.Equal(t, "sess", r.URL.Query().Get("query"))
+		w.Write([]byte(`["session.go","session_test.go"]`))
+	})
+
+	paths, err := oc.FindFiles(context.Background(), "sess")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"session.go", "session_test.go"}, paths)
+}
+
+func TestFindSymbols(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/find/symbol", r.URL.Path)
+		w.Write([]byte(`[{"name":"GetSession","kind":6,"location":{"uri":"file:///work/my%20app/session.go","range":{"start":{"line":41,"character":0},"end":{"line":48,"character":1}}}}]`))
+	})
+
+	symbols, err := oc.FindSymbols(context.Background(), "GetSession")
+	require.NoError(t, err)
+	assert.Equal(t, []Symbol{{
+		Name:  "GetSession",
+		Kind:  6,
+		Path:  "/work/my app/session.go",
+		Range: Range{Start: Position{Line: 41}, End: Position{Line: 48, Character: 1}},
+	}}, symbols)
+	assert.Equal(t, "method", symbols[0].Kind.String())
+	assert.Equal(t, "kind 99", SymbolKind(99).String())
+}
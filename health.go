@@ -0,0 +1,95 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HealthPolicy configures MonitorHealth.
+type HealthPolicy struct {
+	// Interval between checks. Defaults to 10 seconds.
+	Interval time.Duration
+	// Timeout bounds each check. Defaults to 5 seconds.
+	Timeout time.Duration
+	// FailureThreshold is how many consecutive checks must fail before the
+	// server counts as unhealthy. Defaults to 3.
+	FailureThreshold int
+	// OnUnhealthy is called with the last error once the threshold is
+	// reached, e.g. to alert or Restart the server.
+	OnUnhealthy func(err error)
+	// OnRecovered is called when a check succeeds after OnUnhealthy.
+	OnRecovered func()
+}
+
+// MonitorHealth checks /global/health in the background until ctx is done or
+// Close is called. Start it once the server is ready; it assumes the server
+// is healthy to begin with.
+func (oc *OpenCode) MonitorHealth(ctx context.Context, policy HealthPolicy) {
+	done, ok := oc.trackStream()
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(oc.closeCtx, cancel)
+	go func() {
+		defer done()
+		defer cancel()
+		defer stop()
+		oc.monitorHealth(ctx, policy)
+	}()
+}
+
+func (oc *OpenCode) monitorHealth(ctx context.Context, policy HealthPolicy) {
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	timeout := policy.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	threshold := policy.FailureThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	healthy, failures := true, 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := oc.do(checkCtx, http.MethodGet, "/global/health", nil, nil)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			failures = 0
+			if !healthy {
+				healthy = true
+				oc.log.Info("OpenCode recovered", "addr", oc.Addr())
+				if policy.OnRecovered != nil {
+					policy.OnRecovered()
+				}
+			}
+			continue
+		}
+		failures++
+		oc.log.Debug("OpenCode health check failed", "addr", oc.Addr(), "failures", failures, "err", err)
+		if healthy && failures >= threshold {
+			healthy = false
+			oc.log.Warn("OpenCode is unhealthy", "addr", oc.Addr(), "failures", failures, "err", err)
+			if policy.OnUnhealthy != nil {
+				policy.OnUnhealthy(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorHealth(t *testing.T) {
+	var down atomic.Bool
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"healthy":true}`))
+	})
+
+	unhealthy := make(chan error, 1)
+	recovered := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	oc.MonitorHealth(ctx, HealthPolicy{
+		Interval:         10 * time.Millisecond,
+		FailureThreshold: 2,
+		OnUnhealthy:      func(err error) { unhealthy <- err },
+		OnRecovered:      func() { recovered <- struct{}{} },
+	})
+
+	down.Store(true)
+	select {
+	case err := <-unhealthy:
+		assert.EqualError(t, err, "unexpected status code: 503")
+	case <-time.After(time.Second):
+		t.Fatal("OnUnhealthy was not called")
+	}
+
+	down.Store(false)
+	select {
+	case <-recovered:
+	case <-time.After(time.Second):
+		t.Fatal("OnRecovered was not called")
+	}
+
+	// Close stops the monitor
+	assert.NoError(t, oc.Close(context.Background()))
+}
@@ -0,0 +1,347 @@
+package opencode
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// eventHub shares a single upstream event stream between all subscribers. The
+// stream is opened by the first subscriber and closed when the last one leaves.
+type eventHub struct {
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	gen    int
+	cancel context.CancelFunc
+	log    *slog.Logger
+	// connected is closed once the current upstream stream has connected
+	connected chan struct{}
+}
+
+type subscriber struct {
+	ch      chan Event
+	ctx     context.Context
+	filter  EventFilter
+	policy  Backpressure
+	dropped atomic.Int64
+
+	// The coalesce policy queues events here and a pump goroutine feeds ch
+	mu     sync.Mutex
+	queue  []Event
+	size   int
+	closed bool
+	ready  chan struct{}
+	space  chan struct{}
+
+	// stop unregisters the removal on ctx once the hub drops the subscriber
+	stop func() bool
+	// connected is the hub's connected channel at the time of subscribing
+	connected <-chan struct{}
+}
+
+// Backpressure decides what a subscription does when its consumer falls
+// behind and the buffer is full.
+type Backpressure int
+
+const (
+	// BackpressureBlock waits for the consumer, which holds up delivery to
+	// every other subscriber of the shared stream.
+	BackpressureBlock Backpressure = iota
+	// BackpressureDropOldest discards the oldest buffered event to make room.
+	BackpressureDropOldest
+	// BackpressureCoalesce merges consecutive part updates for the same part
+	// while they wait in the buffer, concatenating their deltas, and otherwise
+	// blocks like BackpressureBlock.
+	BackpressureCoalesce
+)
+
+type SubscribeOptions struct {
+	Filter EventFilter
+	// BufferSize defaults to 64.
+	BufferSize   int
+	Backpressure Backpressure
+}
+
+// Subscription is a subscription created by SubscribeWith.
+type Subscription struct {
+	C   <-chan Event
+	sub *subscriber
+}
+
+// connected is closed once the shared stream has connected, so no event
+// published afterwards is missed.
+func (s *Subscription) connected() <-chan struct{} {
+	return s.sub.connected
+}
+
+// Dropped returns how many events were discarded by BackpressureDropOldest or
+// merged into a later update by BackpressureCoalesce.
+func (s *Subscription) Dropped() int64 {
+	return s.sub.dropped.Load()
+}
+
+// EventFilter limits a subscription to events of one session and/or a set of
+// event types. Zero values match everything.
+type EventFilter struct {
+	SessionID string
+	Types     []string
+}
+
+func (f EventFilter) Match(event Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.EventType()) {
+		return false
+	}
+	return f.SessionID == "" || eventSessionID(event) == f.SessionID
+}
+
+func (oc *OpenCode) Subscribe(ctx context.Context, maybeBufferSize ...int) <-chan Event {
+	return oc.SubscribeFiltered(ctx, EventFilter{}, maybeBufferSize...)
+}
+
+func (oc *OpenCode) SubscribeFiltered(ctx context.Context, filter EventFilter, maybeBufferSize ...int) <-chan Event {
+	opts := SubscribeOptions{Filter: filter}
+	if len(maybeBufferSize) > 0 {
+		opts.BufferSize = maybeBufferSize[0]
+	}
+	return oc.SubscribeWith(ctx, opts).C
+}
+
+// SubscribeWith subscribes to the shared event stream with a backpressure
+// policy and exposes the number of dropped events.
+func (oc *OpenCode) SubscribeWith(ctx context.Context, opts SubscribeOptions) *Subscription {
+	bufferSize := opts.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = 64
+	}
+	sub := &subscriber{ctx: ctx, filter: opts.Filter, policy: opts.Backpressure}
+	if sub.policy == BackpressureCoalesce {
+		sub.ch = make(chan Event)
+		sub.size = bufferSize
+		sub.ready = make(chan struct{}, 1)
+		sub.space = make(chan struct{}, 1)
+		go sub.pump()
+	} else {
+		sub.ch = make(chan Event, bufferSize)
+	}
+	subscription := &Subscription{C: sub.ch, sub: sub}
+
+	h := &oc.hub
+	h.mu.Lock()
+	if h.subs == nil {
+		h.subs = make(map[*subscriber]struct{})
+	}
+	h.subs[sub] = struct{}{}
+	if h.cancel == nil {
+		done, ok := oc.trackStream()
+		if !ok {
+			delete(h.subs, sub)
+			h.mu.Unlock()
+			sub.close()
+			return subscription
+		}
+		var upstream context.Context
+		upstream, h.cancel = context.WithCancel(oc.closeCtx)
+		h.gen++
+		h.connected = make(chan struct{})
+		go func(gen int, connected chan struct{}) {
+			defer done()
+			h.run(upstream, oc, gen, connected)
+		}(h.gen, h.connected)
+	}
+	sub.connected = h.connected
+	sub.stop = context.AfterFunc(ctx, func() { h.remove(sub) })
+	h.mu.Unlock()
+	return subscription
+}
+
+// Subscribe delivers only events of type T, e.g.
+// Subscribe[*MessagePartUpdatedEvent](ctx, oc).
+func Subscribe[T Event](ctx context.Context, oc Client, maybeBufferSize ...int) <-chan T {
+	events := oc.Subscribe(ctx, maybeBufferSize...)
+	out := make(chan T, cap(events))
+	go func() {
+		defer close(out)
+		for event := range events {
+			typed, ok := event.(T)
+			if !ok {
+				continue
+			}
+			select {
+			case out <- typed:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
+
+func (h *eventHub) run(ctx context.Context, oc *OpenCode, gen int, connected chan struct{}) {
+	h.log.Debug("Starting shared event stream")
+	var once sync.Once
+	onConnect := func() { once.Do(func() { close(connected) }) }
+	err := oc.streamEvents(ctx, onConnect, func(event Event) {
+		h.broadcast(gen, event)
+	})
+	if err != nil && ctx.Err() == nil {
+		h.log.Warn("Shared event stream failed", "err", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.gen != gen {
+		return
+	}
+	for sub := range h.subs {
+		sub.stop()
+		sub.close()
+		delete(h.subs, sub)
+	}
+	h.cancel = nil
+	h.gen++
+}
+
+func (h *eventHub) broadcast(gen int, event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.gen != gen {
+		return
+	}
+	for sub := range h.subs {
+		if sub.filter.Match(event) {
+			sub.send(event)
+		}
+	}
+}
+
+func (s *subscriber) send(event Event) {
+	switch s.policy {
+	case BackpressureDropOldest:
+		for {
+			select {
+			case s.ch <- event:
+				return
+			default:
+			}
+			// Only the hub sends, so taking one out always makes room
+			select {
+			case <-s.ch:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	case BackpressureCoalesce:
+		for {
+			s.mu.Lock()
+			if n := len(s.queue); n > 0 {
+				if merged, ok := coalesce(s.queue[n-1], event); ok {
+					s.queue[n-1] = merged
+					s.mu.Unlock()
+					s.dropped.Add(1)
+					return
+				}
+			}
+			if len(s.queue) < s.size {
+				s.queue = append(s.queue, event)
+				s.mu.Unlock()
+				notify(s.ready)
+				return
+			}
+			s.mu.Unlock()
+			select {
+			case <-s.space:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	default:
+		select {
+		case s.ch <- event:
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+// coalesce merges next into prev if both update the same part. Events are
+// shared between subscribers, so the result is a copy.
+func coalesce(prev, next Event) (Event, bool) {
+	p, ok := prev.(*MessagePartUpdatedEvent)
+	if !ok {
+		return nil, false
+	}
+	n, ok := next.(*MessagePartUpdatedEvent)
+	if !ok || p.Part == nil || n.Part == nil || p.Part.PartID() != n.Part.PartID() {
+		return nil, false
+	}
+	merged := *n
+	merged.Delta = p.Delta + n.Delta
+	return &merged, true
+}
+
+// pump forwards queued events to the consumer until the subscription is
+// closed and drained or its context ends.
+func (s *subscriber) pump() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return
+			}
+			select {
+			case <-s.ready:
+			case <-s.ctx.Done():
+				return
+			}
+			continue
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		notify(s.space)
+
+		select {
+		case s.ch <- event:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *subscriber) close() {
+	if s.policy != BackpressureCoalesce {
+		close(s.ch)
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	notify(s.ready)
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (h *eventHub) remove(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; !ok {
+		return
+	}
+	sub.close()
+	delete(h.subs, sub)
+
+	if len(h.subs) == 0 && h.cancel != nil {
+		h.log.Debug("Stopping shared event stream")
+		h.cancel()
+		h.cancel = nil
+		h.gen++
+	}
+}
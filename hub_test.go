@@ -0,0 +1,228 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pushServer(t *testing.T) (*OpenCode, chan<- string, *atomic.Int32) {
+	events := make(chan string)
+	var connections atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		connections.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				fmt.Fprintf(w, "data: %s\n\n", event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	})
+	return oc, events, &connections
+}
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestSubscribeSharesConnection(t *testing.T) {
+	oc, events, connections := pushServer(t)
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	sub1 := oc.Subscribe(ctx1)
+	sub2 := oc.Subscribe(ctx2)
+
+	events <- `{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`
+	assert.Equal(t, "message.removed", receive(t, sub1).EventType())
+	assert.Equal(t, "message.removed", receive(t, sub2).EventType())
+	assert.Equal(t, int32(1), connections.Load())
+
+	cancel1()
+	_, ok := <-sub1
+	assert.False(t, ok)
+
+	events <- `{"type":"server.connected","properties":{}}`
+	assert.Equal(t, "server.connected", receive(t, sub2).EventType())
+
+	cancel2()
+	_, ok = <-sub2
+	assert.False(t, ok)
+	oc.hub.mu.Lock()
+	assert.Nil(t, oc.hub.cancel)
+	oc.hub.mu.Unlock()
+}
+
+func TestSubscribeClosesWhenUpstreamEnds(t *testing.T) {
+	oc := newTestServer(t, sseHandler(`{"type":"server.connected","properties":{}}`))
+
+	sub := oc.Subscribe(context.Background())
+	assert.Equal(t, "server.connected", receive(t, sub).EventType())
+	_, ok := <-sub
+	assert.False(t, ok)
+}
+
+// watchedContext counts the callbacks registered on it with
+// context.AfterFunc that have not been stopped yet.
+type watchedContext struct {
+	context.Context
+	done    chan struct{}
+	pending *atomic.Int32
+}
+
+func (c watchedContext) Done() <-chan struct{} { return c.done }
+
+func (c watchedContext) AfterFunc(f func()) func() bool {
+	c.pending.Add(1)
+	var once sync.Once
+	return func() bool {
+		stopped := false
+		once.Do(func() {
+			c.pending.Add(-1)
+			stopped = true
+		})
+		return stopped
+	}
+}
+
+func TestSubscribeReleasesContextWhenUpstreamEnds(t *testing.T) {
+	oc := newTestServer(t, sseHandler())
+
+	ctx := watchedContext{context.Background(), make(chan struct{}), new(atomic.Int32)}
+	for range 3 {
+		sub := oc.Subscribe(ctx)
+		for range sub {
+		}
+	}
+	assert.Zero(t, ctx.pending.Load())
+}
+
+func TestSubscribeFiltered(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	all := oc.Subscribe(ctx)
+	filtered := oc.SubscribeFiltered(ctx, EventFilter{SessionID: "ses_2", Types: []string{"message.part.updated", "session.status"}})
+
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_1","sessionID":"ses_1","type":"text"}}}`
+	events <- `{"type":"message.removed","properties":{"sessionID":"ses_2","messageID":"msg_1"}}`
+	events <- `{"type":"session.status","properties":{"sessionID":"ses_2","status":{"type":"idle"}}}`
+
+	for range 3 {
+		receive(t, all)
+	}
+	event := receive(t, filtered)
+	assert.Equal(t, &SessionStatusEvent{SessionID: "ses_2", Status: SessionStatus{Type: "idle"}}, event)
+	select {
+	case event := <-filtered:
+		t.Fatalf("unexpected event %s", event.EventType())
+	default:
+	}
+}
+
+func TestSubscribeFilteredSkipsEventsWithoutPart(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filtered := oc.SubscribeFiltered(ctx, EventFilter{SessionID: "ses_1"})
+
+	events <- `{"type":"message.part.updated"}`
+	events <- `{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`
+	assert.Equal(t, "message.removed", receive(t, filtered).EventType())
+	assert.Empty(t, eventSessionID(&MessagePartUpdatedEvent{}))
+}
+
+func TestEventFilterUnknownEvent(t *testing.T) {
+	event := &UnknownEvent{Type: "todo.updated", Properties: map[string]any{"sessionID": "ses_1"}}
+	assert.True(t, EventFilter{SessionID: "ses_1"}.Match(event))
+	assert.False(t, EventFilter{SessionID: "ses_2"}.Match(event))
+	assert.False(t, EventFilter{Types: []string{"session.idle"}}.Match(event))
+}
+
+func TestSubscribeTyped(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	parts := Subscribe[*MessagePartUpdatedEvent](ctx, oc)
+
+	events <- `{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_1","type":"text","text":"hi"},"delta":"hi"}}`
+
+	select {
+	case event := <-parts:
+		assert.Equal(t, "hi", event.Delta)
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+
+	cancel()
+	_, ok := <-parts
+	assert.False(t, ok)
+}
+
+func TestSubscribeDropOldest(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := oc.SubscribeWith(ctx, SubscribeOptions{BufferSize: 2, Backpressure: BackpressureDropOldest})
+
+	for i := range 4 {
+		events <- fmt.Sprintf(`{"type":"file.edited","properties":{"file":"%d.go"}}`, i)
+	}
+	assert.Eventually(t, func() bool { return sub.Dropped() == 2 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "2.go", receive(t, sub.C).(*FileEditedEvent).File)
+	assert.Equal(t, "3.go", receive(t, sub.C).(*FileEditedEvent).File)
+}
+
+func TestSubscribeCoalesce(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := oc.SubscribeWith(ctx, SubscribeOptions{BufferSize: 4, Backpressure: BackpressureCoalesce})
+
+	part := `{"type":"message.part.updated","properties":{"part":{"id":"prt_1","type":"text"},"delta":"%s"}}`
+	for _, delta := range []string{"a", "b", "c"} {
+		events <- fmt.Sprintf(part, delta)
+	}
+	events <- `{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`
+	events <- fmt.Sprintf(part, "d")
+	assert.Eventually(t, func() bool { return sub.Dropped() >= 1 }, time.Second, 10*time.Millisecond)
+
+	var deltas string
+	for {
+		event := receive(t, sub.C)
+		if _, ok := event.(*MessageRemovedEvent); ok {
+			break
+		}
+		deltas += event.(*MessagePartUpdatedEvent).Delta
+	}
+	assert.Equal(t, "abc", deltas)
+	assert.Equal(t, "d", receive(t, sub.C).(*MessagePartUpdatedEvent).Delta)
+
+	cancel()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
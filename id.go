@@ -0,0 +1,41 @@
+package opencode
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+var idState struct {
+	sync.Mutex
+	lastTime int64
+	counter  int64
+}
+
+// newID returns an ascending ID in opencode's format, e.g. "msg_" followed by
+// 12 hex digits of time and counter and 14 random base62 characters, for
+// requests where the client picks the ID.
+func newID(prefix string) string {
+	idState.Lock()
+	now := time.Now().UnixMilli()
+	if now != idState.lastTime {
+		idState.lastTime = now
+		idState.counter = 0
+	}
+	idState.counter++
+	value := now*0x1000 + idState.counter
+	idState.Unlock()
+
+	var timeBytes [6]byte
+	for i := range timeBytes {
+		timeBytes[i] = byte(value >> (40 - 8*i))
+	}
+	const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	random := make([]byte, 14)
+	rand.Read(random)
+	for i, b := range random {
+		random[i] = base62[int(b)%len(base62)]
+	}
+	return prefix + "_" + hex.EncodeToString(timeBytes[:]) + string(random)
+}
@@ -0,0 +1,19 @@
+package opencode
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewID(t *testing.T) {
+	pattern := regexp.MustCompile(`^msg_[0-9a-f]{12}[0-9A-Za-z]{14}$`)
+	prev := newID("msg")
+	assert.Regexp(t, pattern, prev)
+	for range 1000 {
+		id := newID("msg")
+		assert.Greater(t, id[:16], prev[:16], "IDs must ascend")
+		prev = id
+	}
+}
@@ -0,0 +1,41 @@
+package opencode
+
+import (
+	"context"
+	"io"
+	"iter"
+)
+
+// Client is the session, message and event API of *OpenCode, so code using
+// it can be tested against a fake instead of a running server.
+type Client interface {
+	ListSessions(ctx context.Context, maybeQuery ...SessionQuery) ([]Session, error)
+	CreateSession(ctx context.Context, create SessionCreate) (*Session, error)
+	GetSession(ctx context.Context, sessionID string) (*Session, error)
+	ListSessionChildren(ctx context.Context, sessionID string) ([]Session, error)
+	UpdateSession(ctx context.Context, sessionID string, update SessionUpdate) (*Session, error)
+	DeleteSession(ctx context.Context, sessionID string) error
+	RevertMessage(ctx context.Context, sessionID, messageID string) (*Session, error)
+	UnrevertSession(ctx context.Context, sessionID string) (*Session, error)
+	SummarizeSession(ctx context.Context, sessionID string, maybeModel ...Model) error
+	InitSession(ctx context.Context, sessionID string, maybeModel ...Model) error
+	AbortSession(ctx context.Context, sessionID string) error
+
+	ListMessages(ctx context.Context, sessionID string) ([]MessageWithParts, error)
+	GetMessage(ctx context.Context, sessionID, messageID string) (*MessageWithParts, error)
+	SendMessage(ctx context.Context, sessionID string, opts ...MessageOption) (*MessageWithParts, error)
+	WaitForCompletion(ctx context.Context, sessionID, messageID string) (*MessageWithParts, error)
+	ResendMessage(ctx context.Context, sessionID, messageID, newText string) (*MessageWithParts, error)
+	Ask(ctx context.Context, sessionID, prompt string) (string, error)
+	StreamResponse(ctx context.Context, sessionID, prompt string, w io.Writer) error
+	RunCommand(ctx context.Context, sessionID, name, args string) (*MessageWithParts, error)
+	RunShell(ctx context.Context, sessionID, command string, maybeAgent ...string) (*MessageWithParts, error)
+
+	StreamEvents(ctx context.Context, callback func(Event)) error
+	Events(ctx context.Context, maybeBufferSize ...int) (<-chan Event, <-chan error)
+	EventSeq(ctx context.Context) iter.Seq2[Event, error]
+	Subscribe(ctx context.Context, maybeBufferSize ...int) <-chan Event
+	SubscribeFiltered(ctx context.Context, filter EventFilter, maybeBufferSize ...int) <-chan Event
+}
+
+var _ Client = (*OpenCode)(nil)
@@ -0,0 +1,28 @@
+package opencode
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient answers Ask from a script; calls to other methods panic.
+type fakeClient struct {
+	Client
+	answers []string
+}
+
+func (f *fakeClient) Ask(ctx context.Context, sessionID, prompt string) (string, error) {
+	answer := f.answers[0]
+	f.answers = f.answers[1:]
+	return answer, nil
+}
+
+func TestClientFake(t *testing.T) {
+	fake := &fakeClient{answers: []string{`{"city":"Oslo","temp":-3}`}}
+	got, err := AskJSON[weather](context.Background(), fake, "ses_1", "weather?", weatherSchema)
+	require.NoError(t, err)
+	assert.Equal(t, weather{City: "Oslo", Temp: -3}, got)
+}
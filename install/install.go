@@ -0,0 +1,258 @@
+// Package install downloads pinned opencode releases so services can run the
+// CLI without npm or bun, e.g.
+//
+//	path, err := install.Install(ctx, install.Options{Version: "0.15.0", SHA256: "..."})
+//	oc := opencode.New(cfg, opencode.WithBinary(path))
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const DefaultBaseURL = "https://github.com/sst/opencode/releases/download"
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+type Options struct {
+	// Version is the release to install, e.g. "0.15.0".
+	Version string
+	// SHA256 is the expected hex checksum of the release archive. It is
+	// required; the error for a missing checksum reports the downloaded one.
+	SHA256 string
+	// CacheDir holds installed versions. Defaults to opencode-go in
+	// os.UserCacheDir().
+	CacheDir string
+	BaseURL  string
+	Client   *http.Client
+	// GOOS and GOARCH select the release asset. Default to the running
+	// platform.
+	GOOS   string
+	GOARCH string
+	// Logger receives progress logs. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Install returns the path of the opencode binary for opts.Version, downloading
+// and verifying it first unless a cached binary was installed from an archive
+// with the pinned checksum and is unchanged.
+func Install(ctx context.Context, opts Options) (string, error) {
+	if opts.Version == "" {
+		return "", fmt.Errorf("version is required")
+	}
+	version := strings.TrimPrefix(opts.Version, "v")
+	goos, goarch := opts.GOOS, opts.GOARCH
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find cache directory: %w", err)
+		}
+		cacheDir = filepath.Join(userCache, "opencode-go")
+	}
+
+	binaryName := "opencode"
+	if goos == "windows" {
+		binaryName += ".exe"
+	}
+	binaryPath := filepath.Join(cacheDir, version, goos+"-"+goarch, binaryName)
+	// Without a pin the download only serves to report its checksum
+	if opts.SHA256 != "" && cached(binaryPath, opts.SHA256) {
+		return binaryPath, nil
+	}
+
+	asset, err := AssetName(goos, goarch)
+	if err != nil {
+		return "", err
+	}
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	url := fmt.Sprintf("%s/v%s/%s", strings.TrimSuffix(baseURL, "/"), version, asset)
+	logger.Debug("Downloading opencode", "version", version, "url", url)
+	archive, err := download(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(archive)
+	got := hex.EncodeToString(sum[:])
+	if opts.SHA256 == "" {
+		return "", fmt.Errorf("SHA256 is required to install opencode %s, the downloaded %s has sha256 %s", version, asset, got)
+	}
+	if !strings.EqualFold(got, opts.SHA256) {
+		return "", fmt.Errorf("%w for %s: got %s, want %s", ErrChecksumMismatch, asset, got, opts.SHA256)
+	}
+
+	binary, err := extract(asset, archive, binaryName)
+	if err != nil {
+		return "", err
+	}
+	if err := writeAtomic(binaryPath, binary, 0755); err != nil {
+		return "", err
+	}
+	binarySum := sha256.Sum256(binary)
+	if err := writeAtomic(binaryPath+".sha256", []byte(got+" "+hex.EncodeToString(binarySum[:])+"\n"), 0644); err != nil {
+		return "", err
+	}
+	logger.Debug("Installed opencode", "version", version, "path", binaryPath)
+	return binaryPath, nil
+}
+
+// cached reports whether binaryPath was installed from an archive with the
+// pinned checksum and has not changed since. The checksums of the archive and
+// the binary are stored next to it.
+func cached(binaryPath, pin string) bool {
+	sums, err := os.ReadFile(binaryPath + ".sha256")
+	if err != nil {
+		return false
+	}
+	archiveSum, binarySum, ok := strings.Cut(strings.TrimSpace(string(sums)), " ")
+	if !ok || !strings.EqualFold(archiveSum, pin) {
+		return false
+	}
+	f, err := os.Open(binaryPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == binarySum
+}
+
+// AssetName returns the release archive name for a platform, e.g.
+// opencode-linux-x64.tar.gz.
+func AssetName(goos, goarch string) (string, error) {
+	arch := map[string]string{"amd64": "x64", "arm64": "arm64"}[goarch]
+	if arch == "" {
+		return "", fmt.Errorf("unsupported architecture %s", goarch)
+	}
+	switch goos {
+	case "linux":
+		return fmt.Sprintf("opencode-linux-%s.tar.gz", arch), nil
+	case "darwin", "windows":
+		return fmt.Sprintf("opencode-%s-%s.zip", goos, arch), nil
+	default:
+		return "", fmt.Errorf("unsupported operating system %s", goos)
+	}
+}
+
+func download(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: unexpected status code: %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return data, nil
+}
+
+func extract(asset string, archive []byte, binaryName string) ([]byte, error) {
+	if strings.HasSuffix(asset, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", asset, err)
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) != binaryName || f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract %s: %w", f.Name, err)
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		return nil, fmt.Errorf("%s does not contain %s", asset, binaryName)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", asset, err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s does not contain %s", asset, binaryName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", asset, err)
+		}
+		if hdr.Typeflag == tar.TypeReg && path.Base(hdr.Name) == binaryName {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// writeAtomic writes a file next to its destination first so concurrent
+// installs never see a partial file.
+func writeAtomic(dest string, data []byte, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create install directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".opencode-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(dest), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(dest), err)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return fmt.Errorf("failed to install %s: %w", filepath.Base(dest), err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package install
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarGz(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(data))}))
+	tw.Write(data)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create(name)
+	require.NoError(t, err)
+	w.Write(data)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func releaseServer(t *testing.T, path string, archive []byte) (string, *atomic.Int32) {
+	t.Helper()
+	var downloads atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		downloads.Add(1)
+		w.Write(archive)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, &downloads
+}
+
+func TestInstallTarGz(t *testing.T) {
+	archive := tarGz(t, "bin/opencode", []byte("#!/bin/sh\n"))
+	url, downloads := releaseServer(t, "/v0.15.0/opencode-linux-x64.tar.gz", archive)
+	opts := Options{
+		Version:  "v0.15.0",
+		SHA256:   checksum(archive),
+		CacheDir: t.TempDir(),
+		BaseURL:  url,
+		GOOS:     "linux",
+		GOARCH:   "amd64",
+	}
+
+	path, err := Install(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(opts.CacheDir, "0.15.0", "linux-amd64", "opencode"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(data))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0100)
+
+	// A second install reuses the cached binary
+	_, err = Install(context.Background(), opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, downloads.Load())
+
+	// A modified binary is downloaded again
+	require.NoError(t, os.WriteFile(path, []byte("tampered"), 0755))
+	_, err = Install(context.Background(), opts)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, downloads.Load())
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\n", string(data))
+
+	// A changed pin is verified against the download, not the cache
+	pin := opts.SHA256
+	opts.SHA256 = checksum([]byte("other"))
+	_, err = Install(context.Background(), opts)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// A missing pin never returns the cached binary
+	opts.SHA256 = ""
+	_, err = Install(context.Background(), opts)
+	assert.ErrorContains(t, err, pin)
+}
+
+func TestInstallZip(t *testing.T) {
+	archive := zipArchive(t, "opencode.exe", []byte("MZ"))
+	url, _ := releaseServer(t, "/v1.0.0/opencode-windows-x64.zip", archive)
+
+	path, err := Install(context.Background(), Options{
+		Version:  "1.0.0",
+		SHA256:   checksum(archive),
+		CacheDir: t.TempDir(),
+		BaseURL:  url,
+		GOOS:     "windows",
+		GOARCH:   "amd64",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "opencode.exe", filepath.Base(path))
+}
+
+func TestInstallChecksum(t *testing.T) {
+	archive := tarGz(t, "opencode", []byte("binary"))
+	url, _ := releaseServer(t, "/v0.15.0/opencode-linux-arm64.tar.gz", archive)
+	opts := Options{Version: "0.15.0", CacheDir: t.TempDir(), BaseURL: url, GOOS: "linux", GOARCH: "arm64"}
+
+	_, err := Install(context.Background(), opts)
+	assert.ErrorContains(t, err, checksum(archive))
+
+	opts.SHA256 = checksum([]byte("other"))
+	_, err = Install(context.Background(), opts)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	_, statErr := os.Stat(filepath.Join(opts.CacheDir, "0.15.0"))
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+func TestInstallNotFound(t *testing.T) {
+	url, _ := releaseServer(t, "/nothing", nil)
+	_, err := Install(context.Background(), Options{Version: "9.9.9", SHA256: "x", CacheDir: t.TempDir(), BaseURL: url, GOOS: "darwin", GOARCH: "arm64"})
+	assert.ErrorContains(t, err, "unexpected status code: 404")
+}
+
+func TestAssetName(t *testing.T) {
+	name, err := AssetName("darwin", "arm64")
+	require.NoError(t, err)
+	assert.Equal(t, "opencode-darwin-arm64.zip", name)
+
+	_, err = AssetName("plan9", "amd64")
+	assert.Error(t, err)
+	_, err = AssetName("linux", "386")
+	assert.Error(t, err)
+}
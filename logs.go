@@ -0,0 +1,140 @@
+package opencode
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// lineBuffer keeps the last lines of the server's output in a ring.
+type lineBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+	// writers are the open streams in the order they were created, whose
+	// unterminated output is shown after the lines
+	writers []*lineWriter
+}
+
+func newLineBuffer(size int) *lineBuffer {
+	if size <= 0 {
+		size = 1000
+	}
+	return &lineBuffer{lines: make([]string, size)}
+}
+
+// writer returns a writer for one output stream, so partial lines from stdout
+// and stderr don't get spliced together. It must be closed once the stream
+// ends.
+func (b *lineBuffer) writer() *lineWriter {
+	w := &lineWriter{buf: b}
+	b.mu.Lock()
+	b.writers = append(b.writers, w)
+	b.mu.Unlock()
+	return w
+}
+
+func (b *lineBuffer) add(line string) {
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// last returns up to n of the most recent lines, all of them if n <= 0.
+func (b *lineBuffer) last(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []string
+	if b.full {
+		lines = append(lines, b.lines[b.next:]...)
+	}
+	lines = append(lines, b.lines[:b.next]...)
+	for _, w := range b.writers {
+		if len(w.partial) > 0 {
+			lines = append(lines, string(w.partial))
+		}
+	}
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+type lineWriter struct {
+	buf *lineBuffer
+	// partial is unterminated output, guarded by buf.mu
+	partial []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	b := w.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.add(string(bytes.TrimSuffix(data[:i], []byte("\r"))))
+		data = data[i+1:]
+	}
+	w.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+// close keeps the stream's unterminated output as its last line and stops
+// tracking the writer.
+func (w *lineWriter) close() {
+	b := w.buf
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(w.partial) > 0 {
+		b.add(string(w.partial))
+		w.partial = nil
+	}
+	b.writers = slices.DeleteFunc(b.writers, func(other *lineWriter) bool { return other == w })
+}
+
+// Logs returns up to n of the most recent lines opencode wrote to stdout and
+// stderr, or every kept line if n <= 0. Output is kept across restarts.
+func (oc *OpenCode) Logs(n int) []string {
+	return oc.logs.last(n)
+}
+
+func multiWriter(writers ...io.Writer) io.Writer {
+	var nonNil []io.Writer
+	for _, w := range writers {
+		if w != nil {
+			nonNil = append(nonNil, w)
+		}
+	}
+	return io.MultiWriter(nonNil...)
+}
+
+type logRequest struct {
+	Service string         `json:"service"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Extra   map[string]any `json:"extra,omitempty"`
+}
+
+// Log writes an entry to the server's log, so application context such as
+// job IDs ends up next to the server's own entries. extra may be nil.
+func (oc *OpenCode) Log(ctx context.Context, level LogLevel, message string, extra map[string]any) error {
+	req := logRequest{Service: "go-client", Level: strings.ToLower(string(level)), Message: message, Extra: extra}
+	if err := oc.do(ctx, http.MethodPost, "/log", req, nil); err != nil {
+		return fmt.Errorf("failed to write log: %w", err)
+	}
+	return nil
+}
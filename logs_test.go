@@ -0,0 +1,51 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineBuffer(t *testing.T) {
+	b := newLineBuffer(3)
+	stdout, stderr := b.writer(), b.writer()
+
+	stdout.Write([]byte("one\ntw"))
+	stderr.Write([]byte("err\r\n"))
+	stdout.Write([]byte("o\nthree\nfour"))
+	assert.Equal(t, []string{"two", "three", "four"}, b.last(3))
+	assert.Equal(t, []string{"err", "two", "three", "four"}, b.last(0))
+	assert.Equal(t, []string{"four"}, b.last(1))
+
+	stdout.Write([]byte("\n"))
+	assert.Equal(t, []string{"two", "three", "four"}, b.last(0))
+
+	// Output left over by an exited process becomes a line of its own
+	stderr.Write([]byte("crash"))
+	stdout.close()
+	stderr.close()
+	assert.Equal(t, []string{"three", "four", "crash"}, b.last(0))
+	assert.Empty(t, b.writers)
+}
+
+func TestLog(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/log", r.URL.Path)
+		var req map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, map[string]any{
+			"service": "go-client",
+			"level":   "warn",
+			"message": "job retried",
+			"extra":   map[string]any{"job": "j-42"},
+		}, req)
+		w.Write([]byte("true"))
+	})
+
+	require.NoError(t, oc.Log(context.Background(), LogLevelWarn, "job retried", map[string]any{"job": "j-42"}))
+}
@@ -0,0 +1,388 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// Message roles.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Finish reasons of assistant messages, as in Message.Finish.
+const (
+	FinishStop          = "stop"
+	FinishLength        = "length"
+	FinishToolCalls     = "tool-calls"
+	FinishContentFilter = "content-filter"
+	FinishError         = "error"
+	FinishOther         = "other"
+	FinishUnknown       = "unknown"
+)
+
+type Message struct {
+	ID         string        `json:"id"`
+	SessionID  string        `json:"sessionID"`
+	Role       string        `json:"role"`
+	Time       MessageTime   `json:"time"`
+	ParentID   string        `json:"parentID,omitempty"`
+	ModelID    string        `json:"modelID,omitempty"`
+	ProviderID string        `json:"providerID,omitempty"`
+	Mode       string        `json:"mode,omitempty"`
+	Cost       float64       `json:"cost,omitempty"`
+	Tokens     *Tokens       `json:"tokens,omitempty"`
+	Finish     string        `json:"finish,omitempty"`
+	Error      *MessageError `json:"error,omitempty"`
+}
+
+type MessageTime struct {
+	Created   int64 `json:"created"`
+	Completed int64 `json:"completed,omitempty"`
+}
+
+type Tokens struct {
+	Input     int         `json:"input"`
+	Output    int         `json:"output"`
+	Reasoning int         `json:"reasoning"`
+	Cache     TokensCache `json:"cache"`
+}
+
+type TokensCache struct {
+	Read  int `json:"read"`
+	Write int `json:"write"`
+}
+
+func (t Tokens) Add(other Tokens) Tokens {
+	return Tokens{
+		Input:     t.Input + other.Input,
+		Output:    t.Output + other.Output,
+		Reasoning: t.Reasoning + other.Reasoning,
+		Cache: TokensCache{
+			Read:  t.Cache.Read + other.Cache.Read,
+			Write: t.Cache.Write + other.Cache.Write,
+		},
+	}
+}
+
+func (t Tokens) Total() int {
+	return t.Input + t.Output + t.Reasoning + t.Cache.Read + t.Cache.Write
+}
+
+type MessageWithParts struct {
+	Info  Message `json:"info"`
+	Parts []Part  `json:"parts"`
+}
+
+func (m *MessageWithParts) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Info  Message           `json:"info"`
+		Parts []json.RawMessage `json:"parts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	m.Info = raw.Info
+	m.Parts = make([]Part, 0, len(raw.Parts))
+	for _, data := range raw.Parts {
+		part, err := decodePart(data)
+		if err != nil {
+			return err
+		}
+		m.Parts = append(m.Parts, part)
+	}
+	return nil
+}
+
+func (m *MessageWithParts) Steps() []*StepFinishPart {
+	var steps []*StepFinishPart
+	for _, part := range m.Parts {
+		if step, ok := part.(*StepFinishPart); ok {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// Usage sums cost and tokens over the message's finished steps.
+func (m *MessageWithParts) Usage() (float64, Tokens) {
+	var cost float64
+	var tokens Tokens
+	for _, step := range m.Steps() {
+		cost += step.Cost
+		tokens = tokens.Add(step.Tokens)
+	}
+	return cost, tokens
+}
+
+func (oc *OpenCode) ListMessages(ctx context.Context, sessionID string) ([]MessageWithParts, error) {
+	var messages []MessageWithParts
+	if err := oc.do(ctx, http.MethodGet, fmt.Sprintf("/session/%s/message", url.PathEscape(sessionID)), nil, &messages); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	return messages, nil
+}
+
+func (oc *OpenCode) GetMessage(ctx context.Context, sessionID, messageID string) (*MessageWithParts, error) {
+	var message MessageWithParts
+	path := fmt.Sprintf("/session/%s/message/%s", url.PathEscape(sessionID), url.PathEscape(messageID))
+	if err := oc.do(ctx, http.MethodGet, path, nil, &message); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s: %w", ErrMessageNotFound, messageID, err)
+		}
+		return nil, fmt.Errorf("message %s: %w", messageID, err)
+	}
+	return &message, nil
+}
+
+func (oc *OpenCode) SendMessage(ctx context.Context, sessionID string, opts ...MessageOption) (message *MessageWithParts, err error) {
+	ctx, span := oc.startSpan(ctx, "opencode.SendMessage")
+	span.SetAttribute("opencode.session.id", sessionID)
+	defer func() {
+		if message != nil {
+			cost, tokens := message.Usage()
+			span.SetAttribute("opencode.message.id", message.Info.ID)
+			span.SetAttribute("opencode.message.finish", message.Info.Finish)
+			span.SetAttribute("opencode.usage.input_tokens", tokens.Input)
+			span.SetAttribute("opencode.usage.output_tokens", tokens.Output)
+			span.SetAttribute("opencode.usage.cost", cost)
+		}
+		span.End(err)
+	}()
+
+	var req messageRequest
+	for _, opt := range opts {
+		if err := opt(&req); err != nil {
+			return nil, err
+		}
+	}
+	if len(req.Parts) == 0 {
+		return nil, errors.New("message has no parts")
+	}
+
+	if oc.config.QueueSends {
+		release, err := oc.acquireSession(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	if req.MessageID != "" {
+		_, err := oc.GetMessage(ctx, sessionID, req.MessageID)
+		if err == nil {
+			oc.log.Debug("Message already sent, waiting for its answer", "session", sessionID, "message", req.MessageID)
+			return oc.WaitForCompletion(ctx, sessionID, req.MessageID)
+		}
+		if !errors.Is(err, ErrMessageNotFound) {
+			return nil, err
+		}
+	}
+
+	oc.log.Debug("Sending message", "session", sessionID, "parts", len(req.Parts))
+	var answer MessageWithParts
+	if err := oc.doPrompt(ctx, http.MethodPost, fmt.Sprintf("/session/%s/message", url.PathEscape(sessionID)), req, &answer); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	return &answer, nil
+}
+
+// WaitForCompletion waits until an assistant message has finished and
+// returns it with its parts, cost and tokens. messageID may also be a user
+// message, in which case it waits for the final answer to it, after any tool
+// call steps. It watches the session's events and polls as a fallback.
+func (oc *OpenCode) WaitForCompletion(ctx context.Context, sessionID, messageID string) (*MessageWithParts, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Subscribe before the first check so no update is missed
+	events := oc.SubscribeFiltered(ctx, EventFilter{
+		SessionID: sessionID,
+		Types:     []string{EventMessageUpdated, EventSessionIdle, EventSessionError},
+	})
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		messages, err := oc.ListMessages(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		answer, err := completedAnswer(messages, messageID)
+		if answer != nil || err != nil {
+			return answer, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				// The event stream is unavailable, keep polling
+				events = nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// completedAnswer returns the finished assistant message for messageID, or
+// nil if it is still running.
+func completedAnswer(messages []MessageWithParts, messageID string) (*MessageWithParts, error) {
+	i := slices.IndexFunc(messages, func(m MessageWithParts) bool { return m.Info.ID == messageID })
+	if i < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	finished := func(info Message) bool {
+		return info.Time.Completed > 0 || info.Error != nil
+	}
+	if messages[i].Info.Role == RoleAssistant {
+		if finished(messages[i].Info) {
+			return &messages[i], nil
+		}
+		return nil, nil
+	}
+
+	// The final answer is the last assistant message, after any tool call
+	// steps
+	var answer *MessageWithParts
+	for i := range messages {
+		if info := messages[i].Info; info.Role == RoleAssistant && info.ParentID == messageID {
+			answer = &messages[i]
+		}
+	}
+	if answer != nil && finished(answer.Info) && answer.Info.Finish != FinishToolCalls {
+		return answer, nil
+	}
+	return nil, nil
+}
+
+func (oc *OpenCode) ResendMessage(ctx context.Context, sessionID, messageID, newText string) (*MessageWithParts, error) {
+	original, err := oc.GetMessage(ctx, sessionID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Info.Role != RoleUser {
+		return nil, fmt.Errorf("message %s is not a user message", messageID)
+	}
+
+	opts := []MessageOption{Text(newText)}
+	for _, part := range original.Parts {
+		if file, ok := part.(*FilePart); ok {
+			opts = append(opts, filePartInput(file))
+		}
+	}
+
+	if _, err := oc.RevertMessage(ctx, sessionID, messageID); err != nil {
+		return nil, err
+	}
+	return oc.SendMessage(ctx, sessionID, opts...)
+}
+
+// sendQueue serializes the sends of one session. waiters counts the sends
+// holding or waiting for it, so the last one can remove it from oc.queues.
+type sendQueue struct {
+	ch      chan struct{}
+	waiters int
+}
+
+// acquireSession waits until no other send of this client is in flight for
+// the session and the session is idle, so prompts from other clients, such as
+// the TUI, are not interleaved either.
+func (oc *OpenCode) acquireSession(ctx context.Context, sessionID string) (func(), error) {
+	oc.queueMu.Lock()
+	if oc.queues == nil {
+		oc.queues = make(map[string]*sendQueue)
+	}
+	queue, ok := oc.queues[sessionID]
+	if !ok {
+		queue = &sendQueue{ch: make(chan struct{}, 1)}
+		oc.queues[sessionID] = queue
+	}
+	queue.waiters++
+	oc.queueMu.Unlock()
+
+	leave := func() {
+		oc.queueMu.Lock()
+		defer oc.queueMu.Unlock()
+		if queue.waiters--; queue.waiters == 0 {
+			delete(oc.queues, sessionID)
+		}
+	}
+	select {
+	case queue.ch <- struct{}{}:
+	case <-ctx.Done():
+		leave()
+		return nil, ctx.Err()
+	}
+	release := func() {
+		<-queue.ch
+		leave()
+	}
+	if err := oc.waitIdle(ctx, sessionID); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// SessionStatus returns whether a session is idle, busy or retrying a
+// failed request.
+func (oc *OpenCode) SessionStatus(ctx context.Context, sessionID string) (SessionStatus, error) {
+	var statuses map[string]SessionStatus
+	if err := oc.do(ctx, http.MethodGet, "/session/status", nil, &statuses); err != nil {
+		return SessionStatus{}, fmt.Errorf("failed to get session status: %w", err)
+	}
+	// Only sessions that are not idle are listed
+	status, ok := statuses[sessionID]
+	if !ok {
+		status.Type = SessionStatusIdle
+	}
+	return status, nil
+}
+
+// waitIdle waits until the session is idle. Servers without the status
+// endpoint count every session as idle.
+func (oc *OpenCode) waitIdle(ctx context.Context, sessionID string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var events <-chan Event
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		status, err := oc.SessionStatus(ctx, sessionID)
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if status.Type == SessionStatusIdle {
+			return nil
+		}
+		if events == nil {
+			oc.log.Debug("Waiting for session to become idle", "session", sessionID, "status", status.Type)
+			events = oc.SubscribeFiltered(ctx, EventFilter{
+				SessionID: sessionID,
+				Types:     []string{EventSessionIdle, EventSessionStatus},
+			})
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				// The event stream is unavailable, keep polling
+				events = make(chan Event)
+			}
+		case <-ticker.C:
+		}
+	}
+}
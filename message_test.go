@@ -0,0 +1,180 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const messagesJSON = `[
+  {
+    "info": {"id": "msg_1", "sessionID": "ses_1", "role": "user", "time": {"created": 1}},
+    "parts": [
+      {"id": "prt_1", "sessionID": "ses_1", "messageID": "msg_1", "type": "text", "text": "hello"},
+      {"id": "prt_2", "sessionID": "ses_1", "messageID": "msg_1", "type": "file", "mime": "image/png", "filename": "a.png", "url": "data:image/png;base64,AA=="}
+    ]
+  },
+  {
+    "info": {"id": "msg_2", "sessionID": "ses_1", "role": "assistant", "time": {"created": 2, "completed": 3}, "modelID": "m", "providerID": "p", "cost": 0.5, "tokens": {"input": 10, "output": 20, "reasoning": 0, "cache": {"read": 0, "write": 0}}},
+    "parts": [
+      {"id": "prt_3", "sessionID": "ses_1", "messageID": "msg_2", "type": "step-start"},
+      {"id": "prt_4", "sessionID": "ses_1", "messageID": "msg_2", "type": "reasoning", "text": "thinking"},
+      {"id": "prt_5", "sessionID": "ses_1", "messageID": "msg_2", "type": "tool", "callID": "call_1", "tool": "bash", "state": {"status": "completed", "input": {"command": "ls"}, "output": "a.go"}},
+      {"id": "prt_6", "sessionID": "ses_1", "messageID": "msg_2", "type": "step-finish", "reason": "stop", "cost": 0.5, "tokens": {"input": 10, "output": 20, "reasoning": 0, "cache": {"read": 0, "write": 0}}},
+      {"id": "prt_7", "sessionID": "ses_1", "messageID": "msg_2", "type": "snapshot", "snapshot": "abc"}
+    ]
+  }
+]`
+
+func TestListMessages(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/session/ses_1/message", r.URL.Path)
+		w.Write([]byte(messagesJSON))
+	})
+
+	messages, err := oc.ListMessages(context.Background(), "ses_1")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	assert.Equal(t, "user", messages[0].Info.Role)
+	require.Len(t, messages[0].Parts, 2)
+	assert.Equal(t, "hello", messages[0].Parts[0].(*TextPart).Text)
+	assert.Equal(t, "image/png", messages[0].Parts[1].(*FilePart).Mime)
+
+	assistant := messages[1]
+	assert.Equal(t, 20, assistant.Info.Tokens.Output)
+	require.Len(t, assistant.Parts, 5)
+	assert.IsType(t, &StepStartPart{}, assistant.Parts[0])
+	assert.Equal(t, "thinking", assistant.Parts[1].(*ReasoningPart).Text)
+	tool := assistant.Parts[2].(*ToolPart)
+	assert.Equal(t, "bash", tool.Tool)
+	assert.Equal(t, ToolCompleted, tool.State)
+	assert.Equal(t, "ls", tool.Input["command"])
+	assert.Equal(t, "a.go", tool.Output)
+	assert.True(t, tool.Done())
+	assert.Equal(t, "stop", assistant.Parts[3].(*StepFinishPart).Reason)
+	unknown := assistant.Parts[4].(*UnknownPart)
+	assert.Equal(t, "snapshot", unknown.PartType())
+	assert.Equal(t, "prt_7", unknown.PartID())
+}
+
+func TestGetMessage(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/session/ses_1/message/msg_1", r.URL.Path)
+		w.Write([]byte(`{"info":{"id":"msg_1","role":"assistant","finish":"stop"},"parts":[{"id":"prt_1","type":"text","text":"done"}]}`))
+	})
+
+	message, err := oc.GetMessage(context.Background(), "ses_1", "msg_1")
+	require.NoError(t, err)
+	assert.Equal(t, "stop", message.Info.Finish)
+	require.Len(t, message.Parts, 1)
+	assert.Equal(t, "done", message.Parts[0].(*TextPart).Text)
+}
+
+func TestGetMessageNotFound(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := oc.GetMessage(context.Background(), "ses_1", "msg_missing")
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
+
+func TestResendMessage(t *testing.T) {
+	var calls []string
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/session/ses_1/message/msg_1":
+			w.Write([]byte(`{"info":{"id":"msg_1","role":"user"},"parts":[
+				{"id":"prt_1","type":"text","text":"old"},
+				{"id":"prt_2","type":"file","mime":"image/png","filename":"a.png","url":"data:image/png;base64,AA=="}
+			]}`))
+		case "/session/ses_1/revert":
+			w.Write([]byte(`{"id":"ses_1","revert":{"messageID":"msg_1"}}`))
+		case "/session/ses_1/message":
+			var req messageRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, []partInput{
+				{Type: "text", Text: "new"},
+				{Type: "file", Mime: "image/png", Filename: "a.png", URL: "data:image/png;base64,AA=="},
+			}, req.Parts)
+			w.Write([]byte(`{"info":{"id":"msg_3","role":"assistant"},"parts":[]}`))
+		}
+	})
+
+	message, err := oc.ResendMessage(context.Background(), "ses_1", "msg_1", "new")
+	require.NoError(t, err)
+	assert.Equal(t, "msg_3", message.Info.ID)
+	assert.Equal(t, []string{
+		"GET /session/ses_1/message/msg_1",
+		"POST /session/ses_1/revert",
+		"POST /session/ses_1/message",
+	}, calls)
+}
+
+func TestResendMessageRejectsAssistant(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant"},"parts":[]}`))
+	})
+
+	_, err := oc.ResendMessage(context.Background(), "ses_1", "msg_2", "new")
+	assert.EqualError(t, err, "message msg_2 is not a user message")
+}
+
+func TestMessageUsage(t *testing.T) {
+	message := &MessageWithParts{Parts: []Part{
+		&StepStartPart{},
+		&StepFinishPart{Reason: "tool-calls", Cost: 0.01, Tokens: Tokens{Input: 100, Output: 10, Cache: TokensCache{Read: 50}}},
+		&StepStartPart{},
+		&TextPart{Text: "done"},
+		&StepFinishPart{Reason: "stop", Cost: 0.02, Tokens: Tokens{Input: 120, Output: 30, Reasoning: 5}},
+	}}
+
+	steps := message.Steps()
+	require.Len(t, steps, 2)
+	assert.Equal(t, "tool-calls", steps[0].Reason)
+	assert.Equal(t, "stop", steps[1].Reason)
+
+	cost, tokens := message.Usage()
+	assert.InDelta(t, 0.03, cost, 1e-9)
+	assert.Equal(t, Tokens{Input: 220, Output: 40, Reasoning: 5, Cache: TokensCache{Read: 50}}, tokens)
+	assert.Equal(t, 315, tokens.Total())
+}
+
+func TestWaitForCompletion(t *testing.T) {
+	var polls atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			sseHandler(`{"type":"message.updated","properties":{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant"}}}`)(w, r)
+		case "/session/ses_1/message":
+			finished := ""
+			if polls.Add(1) > 1 {
+				finished = `,"completed":5`
+			}
+			w.Write([]byte(`[
+				{"info":{"id":"msg_1","role":"user"},"parts":[]},
+				{"info":{"id":"msg_2","role":"assistant","parentID":"msg_1","cost":0.5,"time":{"created":3` + finished + `}},"parts":[{"type":"text","text":"done"}]}
+			]`))
+		}
+	})
+
+	ctx := context.Background()
+	answer, err := oc.WaitForCompletion(ctx, "ses_1", "msg_1")
+	require.NoError(t, err)
+	assert.Equal(t, "msg_2", answer.Info.ID)
+	assert.Equal(t, "done", answer.Text())
+
+	answer, err = oc.WaitForCompletion(ctx, "ses_1", "msg_2")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, answer.Info.Cost)
+
+	_, err = oc.WaitForCompletion(ctx, "ses_1", "msg_9")
+	assert.ErrorIs(t, err, ErrMessageNotFound)
+}
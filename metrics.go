@@ -0,0 +1,53 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Metrics receives instrumentation from the client. Implementations must be
+// safe for concurrent use; the opencodeprom package exports them to
+// Prometheus.
+type Metrics interface {
+	// ObserveRequest is called once the response headers of a request have
+	// arrived, or it failed, in which case status is zero. route is the path
+	// with IDs replaced, as returned by Route.
+	ObserveRequest(method, route string, status int, duration time.Duration)
+	// ObserveEvent is called for every event received on an event stream.
+	ObserveEvent(event Event)
+	// ObserveReconnect is called when an event stream reconnects.
+	ObserveReconnect()
+	// ObserveRestart is called when the server was restarted by Restart or
+	// Config.AutoRestart.
+	ObserveRestart()
+}
+
+type routeKey struct{}
+
+// Route returns the API route of a request made by this package, with IDs
+// such as "ses_..." replaced by ":id", e.g. "/session/:id/message". Use it
+// in middleware to label requests without unbounded cardinality.
+func Route(req *http.Request) string {
+	route, _ := req.Context().Value(routeKey{}).(string)
+	return route
+}
+
+var idSegment = regexp.MustCompile(`^[a-z]{3}_[0-9A-Za-z]+$`)
+
+func apiRoute(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func withRoute(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, routeKey{}, apiRoute(path))
+}
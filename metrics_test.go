@@ -0,0 +1,59 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []string
+	events   []string
+}
+
+func (m *recordingMetrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, fmt.Sprintf("%s %s %d", method, route, status))
+}
+
+func (m *recordingMetrics) ObserveEvent(event Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event.EventType())
+}
+
+func (m *recordingMetrics) ObserveReconnect() {}
+func (m *recordingMetrics) ObserveRestart()   {}
+
+func TestMetrics(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/event" {
+			sseHandler(`{"type":"server.connected","properties":{}}`)(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	metrics := &recordingMetrics{}
+	oc.config.Metrics = metrics
+
+	_, err := oc.GetMessage(context.Background(), "ses_1Abc", "msg_2xyz")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, oc.StreamEvents(context.Background(), func(Event) {}))
+
+	assert.Equal(t, []string{"GET /session/:id/message/:id 404", "GET /event 200"}, metrics.requests)
+	assert.Equal(t, []string{EventServerConnected}, metrics.events)
+}
+
+func TestAPIRoute(t *testing.T) {
+	assert.Equal(t, "/session/:id/message", apiRoute("/session/ses_01JABC/message"))
+	assert.Equal(t, "/file/content", apiRoute("/file/content?path=ses_x"))
+	assert.Equal(t, "/auth/anthropic", apiRoute("/auth/anthropic"))
+}
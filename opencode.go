@@ -2,110 +2,366 @@ package opencode
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"sync"
+	"text/template"
 	"time"
+
+	"github.com/ai-shift/opencode/opencodeconfig"
 )
 
+var ErrBinaryNotFound = errors.New("opencode binary not found")
+
+const DefaultRequestTimeout = 30 * time.Second
+
 type Config struct {
-	Addr     string
+	// Addr is the server's host:port, set by Start. To use a server running
+	// elsewhere, set it to a URL such as "https://agents.example.com/opencode".
+	Addr string
+	// Headers are set on every request, including the event stream, e.g.
+	// for a reverse proxy in front of a remote server.
+	Headers map[string]string
+	// BearerToken is sent as "Authorization: Bearer <token>" on every
+	// request and takes precedence over an Authorization header in Headers.
+	BearerToken string
+	// BinaryPath is the opencode executable to run. Defaults to looking up
+	// "opencode" on PATH.
+	BinaryPath string
+	// MinVersion makes Start fail with ErrVersionTooOld when opencode
+	// --version reports an older version.
+	MinVersion string
+	// Hostname is the interface the spawned server binds to. Defaults to
+	// 127.0.0.1.
+	Hostname string
+	// Port makes Start listen on a fixed port instead of a random free one.
+	Port int
+	// Socket connects to a server listening on a unix domain socket instead
+	// of Addr. opencode serve cannot listen on one itself, so Start refuses to
+	// run with it set.
+	Socket   string
 	ConfigFS fs.FS
-	CWD      string
+	// OpenCodeConfig is written to config.json in the config directory, so
+	// ConfigFS must not contain one.
+	OpenCodeConfig *opencodeconfig.Config
+	// CWD is the working tree the agent operates in, independent of where
+	// config and data live.
+	CWD string
+	// ConfigRender selects how ConfigFS files are rendered. Defaults to
+	// RenderEnv.
+	ConfigRender ConfigRender
+	// TemplateData is passed to RenderTemplate templates. Defaults to a map
+	// with the server environment under "Env".
+	TemplateData  any
+	TemplateFuncs template.FuncMap
+	// ConfigVerbatim lists path.Match patterns of ConfigFS files to copy
+	// without rendering. Files containing NUL bytes are always copied as is.
+	ConfigVerbatim []string
+	// ConfigDir is used as the opencode config directory instead of a
+	// temporary one. ConfigFS files are written into it and Cleanup leaves it
+	// in place.
+	ConfigDir string
+	// TempDir is the parent of the temporary config directory. Defaults to
+	// os.TempDir(), which honours TMPDIR.
+	TempDir string
+	// ConfigDirMode is the permission of created config directories.
+	// Defaults to 0700 so other users on the host cannot read the config.
+	ConfigDirMode os.FileMode
+	// DataDir relocates opencode's session storage by setting XDG_DATA_HOME.
+	// Defaults to the user's data directory.
+	DataDir string
+	// Env is merged into the server's environment, overriding inherited
+	// variables, and is also used to expand variables in ConfigFS files.
+	Env map[string]string
+	// ProviderKeys maps provider IDs such as "anthropic" or "openrouter" to
+	// API keys, passed to the server in the variables each provider reads.
+	// Env takes precedence over them.
+	ProviderKeys map[string]string
+	// Stdout and Stderr receive the server's output, which is discarded when
+	// they are nil. Either way the last LogLines lines are kept for Logs.
+	Stdout io.Writer
+	Stderr io.Writer
+	// LogLines is how many lines of output Logs keeps. Defaults to 1000.
+	LogLines int
+	// LogLevel is passed to opencode serve as --log-level when set.
+	LogLevel LogLevel
+	// ExtraArgs are appended to the opencode serve command line, e.g.
+	// "--print-logs".
+	ExtraArgs []string
+	// Middleware wraps every request, including the event stream, in order:
+	// the first one sees the request first.
+	Middleware []Middleware
+	// RequestTimeout bounds API calls whose context has no deadline.
+	// Defaults to DefaultRequestTimeout, negative disables it. Calls that
+	// wait for the agent, such as SendMessage, are only bounded by their
+	// context.
+	RequestTimeout time.Duration
+	// QueueSends serializes SendMessage calls per session so a prompt is
+	// only dispatched once the previous one has finished and the session is
+	// idle, including when another client such as the TUI is using it.
+	QueueSends bool
+	// EventReconnect makes event streams reconnect after the connection
+	// drops instead of returning.
+	EventReconnect *ReconnectPolicy
+	// EventIdleTimeout drops event streams that receive no data, including
+	// keep-alive comments, for this long.
+	EventIdleTimeout time.Duration
+	// MaxEventSize is the largest event data the event stream accepts, in
+	// bytes. Larger events are skipped and reported to OnEventTooLarge.
+	// Defaults to DefaultMaxEventSize, negative means unlimited.
+	MaxEventSize int
+	// OnEventTooLarge is called with the size of each skipped event.
+	OnEventTooLarge func(size int)
+	// StopTimeout is how long Stop waits for a graceful exit before killing
+	// the process group. Defaults to 10 seconds.
+	StopTimeout time.Duration
+	// RestartOnNewPort makes Restart allocate a fresh port instead of
+	// reusing the current one.
+	RestartOnNewPort bool
+	// OnRestart is called after Restart or AutoRestart brought the server back
+	// up, e.g. to resubscribe to events.
+	OnRestart func()
+	// AutoRestart restarts the server when it crashes. Nil disables it.
+	AutoRestart *RestartPolicy
+	// Metrics receives request, event and restart instrumentation.
+	Metrics Metrics
+	// Tracer traces Start, WaitForReady, SendMessage and every request, and
+	// propagates the trace context to the server in request headers.
+	Tracer Tracer
+	// Logger receives the client's logs. Defaults to slog.Default().
+	Logger *slog.Logger
+	// LogVerbose logs credentials and prompt or command content, which are
+	// redacted by default.
+	LogVerbose bool
 }
 
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "DEBUG"
+	LogLevelInfo  LogLevel = "INFO"
+	LogLevelWarn  LogLevel = "WARN"
+	LogLevelError LogLevel = "ERROR"
+)
+
 type OpenCode struct {
-	config    Config
-	cmd       *exec.Cmd
-	client    *http.Client
-	configDir string
-	mu        sync.Mutex
+	config Config
+	cmd    *exec.Cmd
+	client *http.Client
+	// streamClient is client without its timeout, for event streams
+	streamClient *http.Client
+	configDir    string
+	// ownsConfigDir is set when configDir is a temporary directory that
+	// Cleanup removes
+	ownsConfigDir bool
+	exited        chan struct{}
+	mu            sync.Mutex
+
+	logs *lineBuffer
+	log  *slog.Logger
+
+	// addr is the server address, which Start sets while requests may be
+	// running
+	addrMu sync.RWMutex
+	addr   string
+
+	exitMu    sync.Mutex
+	done      chan error
+	exitState *ExitState
+
+	queueMu sync.Mutex
+	queues  map[string]*sendQueue
+
+	hub eventHub
+
+	// closeCtx is cancelled by Close to end all event streams, which are
+	// tracked in streams. streamsMu orders adding streams against Close.
+	closeCtx     context.Context
+	closeStreams context.CancelFunc
+	streamsMu    sync.Mutex
+	streams      sync.WaitGroup
+
+	// restarts records recent automatic restarts, guarded by mu
+	restarts []time.Time
+	// stopSupervisor is closed by Stop to end supervision, guarded by mu
+	stopSupervisor chan struct{}
 }
 
-func New(cfg Config) *OpenCode {
-	return &OpenCode{
-		config: cfg,
-		client: &http.Client{},
+type Option func(*OpenCode)
+
+// WithBinary runs the opencode executable at path, see Config.BinaryPath.
+func WithBinary(path string) Option {
+	return func(oc *OpenCode) {
+		oc.config.BinaryPath = path
 	}
 }
 
-func (oc *OpenCode) Start() error {
-	oc.mu.Lock()
-	defer oc.mu.Unlock()
+// WithHTTPClient sends requests through a copy of client, e.g. one with
+// timeouts or a proxy. With Config.Socket set, its transport must dial the
+// socket itself.
+func WithHTTPClient(client *http.Client) Option {
+	return func(oc *OpenCode) {
+		c := *client
+		oc.client = &c
+	}
+}
 
-	if oc.cmd != nil && oc.cmd.Process != nil {
-		return fmt.Errorf("opencode is already running")
+// WithTransport sends requests through transport, e.g. an *http.Transport
+// with custom root CAs or client certificates for mTLS.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(oc *OpenCode) {
+		oc.client.Transport = transport
 	}
+}
 
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		return fmt.Errorf("failed to get free port: %w", err)
+func New(cfg Config, opts ...Option) *OpenCode {
+	oc := &OpenCode{
+		config: cfg,
+		client: &http.Client{Transport: newTransport(cfg.Socket)},
+		logs:   newLineBuffer(cfg.LogLines),
+		addr:   cfg.Addr,
+		log:    newLogger(cfg),
 	}
-	addr := listener.Addr().(*net.TCPAddr)
-	listener.Close()
-	port := addr.Port
-	oc.config.Addr = fmt.Sprintf("127.0.0.1:%d", port)
-	slog.Info("Allocated random port", "port", port)
+	oc.hub.log = oc.log
+	oc.closeCtx, oc.closeStreams = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(oc)
+	}
+	streamClient := *oc.client
+	streamClient.Timeout = 0
+	oc.streamClient = &streamClient
+	return oc
+}
 
-	if oc.config.ConfigFS != nil {
-		hashBytes := make([]byte, 8)
-		if _, err := rand.Read(hashBytes); err != nil {
-			return fmt.Errorf("failed to generate random hash: %w", err)
+// newTransport keeps enough idle connections to reuse them across the many
+// small concurrent API calls, and dials socket instead of TCP when set.
+func newTransport(socket string) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 32
+	transport.IdleConnTimeout = 90 * time.Second
+	if socket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
 		}
-		hash := hex.EncodeToString(hashBytes)
-		oc.configDir = filepath.Join("/tmp", fmt.Sprintf("opencode_%s", hash))
+	}
+	return transport
+}
 
-		if err := os.MkdirAll(oc.configDir, 0755); err != nil {
-			return fmt.Errorf("failed to create config directory: %w", err)
-		}
-		slog.Info("Created config directory", "path", oc.configDir)
+func (oc *OpenCode) Start() (err error) {
+	_, span := oc.startSpan(context.Background(), "opencode.Start")
+	defer func() { span.End(err) }()
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
 
-		if err := fs.WalkDir(oc.config.ConfigFS, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			if d.IsDir() {
-				return nil
-			}
+	if oc.config.Socket != "" {
+		return fmt.Errorf("opencode serve cannot listen on unix socket %s", oc.config.Socket)
+	}
+	if err := oc.start(0); err != nil {
+		return err
+	}
+	span.SetAttribute("opencode.addr", oc.Addr())
+	return nil
+}
 
-			content, err := fs.ReadFile(oc.config.ConfigFS, path)
-			if err != nil {
-				return fmt.Errorf("failed to read file %s: %w", path, err)
-			}
+// Restart stops opencode gracefully and starts it again with the same config
+// directory and, unless Config.RestartOnNewPort is set, the same port.
+func (oc *OpenCode) Restart(ctx context.Context) error {
+	if err := oc.Stop(ctx); err != nil {
+		return err
+	}
 
-			// Expand environment variables in the content
-			expandedContent := []byte(os.ExpandEnv(string(content)))
+	oc.mu.Lock()
+	port := 0
+	if !oc.config.RestartOnNewPort {
+		port = oc.currentPort()
+	}
+	err := oc.start(port)
+	oc.mu.Unlock()
+	if err != nil {
+		return err
+	}
 
-			destPath := filepath.Join(oc.configDir, path)
-			if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-				return fmt.Errorf("failed to create directory for %s: %w", destPath, err)
-			}
+	oc.log.Info("OpenCode restarted", "addr", oc.Addr())
+	if oc.config.Metrics != nil {
+		oc.config.Metrics.ObserveRestart()
+	}
+	if oc.config.OnRestart != nil {
+		oc.config.OnRestart()
+	}
+	return nil
+}
 
-			if err := os.WriteFile(destPath, expandedContent, 0644); err != nil {
-				return fmt.Errorf("failed to write file %s: %w", destPath, err)
-			}
+func (oc *OpenCode) start(port int) error {
+	if oc.cmd != nil && oc.cmd.Process != nil {
+		return fmt.Errorf("opencode is already running")
+	}
 
-			return nil
-		}); err != nil {
-			return fmt.Errorf("failed to walk config fs: %w", err)
+	binary := oc.config.BinaryPath
+	if binary == "" {
+		binary = "opencode"
+	}
+	binary, err := exec.LookPath(binary)
+	if err != nil {
+		return fmt.Errorf("failed to start opencode: %w: %w (install opencode or set Config.BinaryPath)", ErrBinaryNotFound, err)
+	}
+	if oc.config.MinVersion != "" {
+		version, err := checkVersion(binary, oc.config.MinVersion, oc.environ())
+		if err != nil {
+			return fmt.Errorf("failed to start opencode: %w", err)
 		}
+		oc.log.Debug("Checked opencode version", "version", version, "min", oc.config.MinVersion)
 	}
 
-	args := []string{"serve"}
+	hostname := oc.config.Hostname
+	if hostname == "" {
+		hostname = "127.0.0.1"
+	}
+	if port == 0 {
+		port = oc.config.Port
+	}
+	if port == 0 {
+		listener, err := net.Listen("tcp", net.JoinHostPort(hostname, "0"))
+		if err != nil {
+			return fmt.Errorf("failed to get free port: %w", err)
+		}
+		addr := listener.Addr().(*net.TCPAddr)
+		listener.Close()
+		port = addr.Port
+		oc.log.Debug("Allocated random port", "port", port)
+	}
+	oc.addrMu.Lock()
+	oc.addr = net.JoinHostPort(dialHost(hostname), strconv.Itoa(port))
+	oc.addrMu.Unlock()
 
-	hostname := "127.0.0.1"
-	args = append(args, "--hostname", hostname, "--port", fmt.Sprintf("%d", port))
+	// The config directory survives Stop so restarts reuse it until Cleanup
+	if oc.configDir == "" {
+		if err := oc.ValidateConfig(); err != nil {
+			return err
+		}
+		if err := oc.provisionConfig(); err != nil {
+			return err
+		}
+	}
+	if oc.config.DataDir != "" {
+		if err := os.MkdirAll(oc.config.DataDir, 0755); err != nil {
+			return fmt.Errorf("failed to create data directory: %w", err)
+		}
+	}
 
-	oc.cmd = exec.Command("opencode", args...)
-	oc.cmd.Env = os.Environ()
+	oc.cmd = exec.Command(binary, oc.args(hostname, port)...)
+	oc.cmd.Env = oc.environ()
 
 	if oc.configDir != "" {
 		configJSONPath := filepath.Join(oc.configDir, "config.json")
@@ -113,53 +369,283 @@ func (oc *OpenCode) Start() error {
 			fmt.Sprintf("OPENCODE_CONFIG=%s", configJSONPath),
 			fmt.Sprintf("OPENCODE_CONFIG_DIR=%s", oc.configDir),
 		)
-		slog.Info("Set config environment variables", "config", configJSONPath, "dir", oc.configDir)
+		oc.log.Debug("Set config environment variables", "config", configJSONPath, "dir", oc.configDir)
 	}
 
 	if oc.config.CWD != "" {
 		oc.cmd.Dir = oc.config.CWD
-		slog.Info("Set working directory for opencode process", "cwd", oc.config.CWD)
+		oc.log.Debug("Set working directory for opencode process", "cwd", oc.config.CWD)
 	}
 
-	// Redirect stderr to see error messages
-	oc.cmd.Stderr = os.Stderr
-	oc.cmd.Stdout = os.Stdout
+	oc.cmd.Stdout = oc.config.Stdout
+	oc.cmd.Stderr = oc.config.Stderr
 
-	slog.Info("Starting opencode", "args", oc.cmd.Args)
+	oc.log.Info("Starting opencode", "args", oc.cmd.Args)
 
-	if err := oc.cmd.Start(); err != nil {
+	if err := oc.launch(); err != nil {
 		return fmt.Errorf("failed to start opencode: %w", err)
 	}
-	slog.Info("OpenCode process started", "pid", oc.cmd.Process.Pid)
+	oc.log.Info("OpenCode process started", "pid", oc.cmd.Process.Pid)
 
+	if oc.config.AutoRestart != nil {
+		if oc.stopSupervisor == nil {
+			oc.stopSupervisor = make(chan struct{})
+		}
+		go oc.supervise(oc.cmd, oc.exited, oc.stopSupervisor)
+	}
 	return nil
 }
 
-func (oc *OpenCode) Stop() error {
+// provisionConfig picks the config directory, a temporary one unless
+// Config.ConfigDir is set, and writes the ConfigFS files into it.
+func (oc *OpenCode) provisionConfig() error {
+	dirMode := oc.config.ConfigDirMode
+	if dirMode == 0 {
+		dirMode = 0700
+	}
+	// Check the typed config before creating anything
+	var configJSON []byte
+	if oc.config.OpenCodeConfig != nil {
+		if oc.config.ConfigFS != nil {
+			if _, err := fs.Stat(oc.config.ConfigFS, "config.json"); err == nil {
+				return fmt.Errorf("ConfigFS contains config.json, which conflicts with OpenCodeConfig")
+			}
+		}
+		var err error
+		if configJSON, err = oc.config.OpenCodeConfig.JSON(); err != nil {
+			return fmt.Errorf("invalid opencode config: %w", err)
+		}
+	}
+	switch {
+	case oc.config.ConfigDir != "":
+		if err := os.MkdirAll(oc.config.ConfigDir, dirMode); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		oc.configDir = oc.config.ConfigDir
+	case oc.config.ConfigFS != nil || oc.config.OpenCodeConfig != nil:
+		configDir, err := os.MkdirTemp(oc.config.TempDir, "opencode_*")
+		if err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+		if err := os.Chmod(configDir, dirMode); err != nil {
+			os.RemoveAll(configDir)
+			return fmt.Errorf("failed to set config directory permissions: %w", err)
+		}
+		oc.configDir = configDir
+		oc.ownsConfigDir = true
+		oc.log.Debug("Created config directory", "path", oc.configDir)
+	default:
+		return nil
+	}
+
+	if configJSON != nil {
+		if err := os.WriteFile(filepath.Join(oc.configDir, "config.json"), configJSON, 0644); err != nil {
+			return fmt.Errorf("failed to write config.json: %w", err)
+		}
+	}
+	if oc.config.ConfigFS == nil {
+		return nil
+	}
+
+	if err := fs.WalkDir(oc.config.ConfigFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		content, err := fs.ReadFile(oc.config.ConfigFS, path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+
+		expandedContent, err := oc.renderConfigFile(path, content)
+		if err != nil {
+			return err
+		}
+
+		destPath := filepath.Join(oc.configDir, path)
+		if err := os.MkdirAll(filepath.Dir(destPath), dirMode); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", destPath, err)
+		}
+
+		if err := os.WriteFile(destPath, expandedContent, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", destPath, err)
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walk config fs: %w", err)
+	}
+	return nil
+}
+
+func (oc *OpenCode) getenv(key string) string {
+	value, _ := oc.lookupEnv(key)
+	return value
+}
+
+func (oc *OpenCode) lookupEnv(key string) (string, bool) {
+	if value, ok := oc.config.Env[key]; ok {
+		return value, true
+	}
+	for provider, apiKey := range oc.config.ProviderKeys {
+		if ProviderKeyEnv(provider) == key {
+			return apiKey, true
+		}
+	}
+	return os.LookupEnv(key)
+}
+
+// environ returns the parent environment with Config.Env applied. Later
+// entries win, so overrides are appended in a stable order.
+func (oc *OpenCode) environ() []string {
+	env := os.Environ()
+	if oc.config.DataDir != "" {
+		env = append(env, "XDG_DATA_HOME="+oc.config.DataDir)
+	}
+	for _, provider := range slices.Sorted(maps.Keys(oc.config.ProviderKeys)) {
+		env = append(env, ProviderKeyEnv(provider)+"="+oc.config.ProviderKeys[provider])
+	}
+	for _, key := range slices.Sorted(maps.Keys(oc.config.Env)) {
+		env = append(env, key+"="+oc.config.Env[key])
+	}
+	return env
+}
+
+func (oc *OpenCode) args(hostname string, port int) []string {
+	args := []string{"serve", "--hostname", hostname, "--port", strconv.Itoa(port)}
+	if oc.config.LogLevel != "" {
+		args = append(args, "--log-level", string(oc.config.LogLevel))
+	}
+	return append(args, oc.config.ExtraArgs...)
+}
+
+func (oc *OpenCode) launch() error {
+	setProcessGroup(oc.cmd)
+	stderr := newTailBuffer(4096)
+	stdoutLog, stderrLog := oc.logs.writer(), oc.logs.writer()
+	oc.cmd.Stdout = multiWriter(oc.cmd.Stdout, stdoutLog)
+	oc.cmd.Stderr = multiWriter(oc.cmd.Stderr, stderrLog, stderr)
+	// Don't let descendants holding the output pipes keep Wait from returning
+	oc.cmd.WaitDelay = time.Second
+	if err := oc.cmd.Start(); err != nil {
+		stdoutLog.close()
+		stderrLog.close()
+		return err
+	}
+
+	exited := make(chan struct{})
+	done := make(chan error, 1)
+	oc.exitMu.Lock()
+	oc.exited = exited
+	oc.done = done
+	oc.exitState = nil
+	oc.exitMu.Unlock()
+
+	cmd := oc.cmd
+	go func() {
+		err := cmd.Wait()
+		// Wait has copied all output by now
+		stdoutLog.close()
+		stderrLog.close()
+		state := newExitState(cmd.Process.Pid, err, stderr.String())
+		oc.log.Info("OpenCode process exited", "pid", state.PID, "code", state.Code, "err", err)
+
+		oc.exitMu.Lock()
+		oc.exitState = state
+		oc.exitMu.Unlock()
+		if state.Code != 0 || state.Err != nil {
+			done <- &ExitError{ExitState: *state}
+		}
+		close(done)
+		close(exited)
+	}()
+	return nil
+}
+
+// Stop asks opencode to shut down with SIGTERM and kills its process group if
+// it has not exited after Config.StopTimeout or when ctx is done. Descendants
+// that started their own process group or session are killed as well.
+func (oc *OpenCode) Stop(ctx context.Context) error {
 	oc.mu.Lock()
 	defer oc.mu.Unlock()
 
+	// Wakes a supervisor waiting to restart a crashed process
+	if oc.stopSupervisor != nil {
+		close(oc.stopSupervisor)
+		oc.stopSupervisor = nil
+	}
 	if oc.cmd == nil || oc.cmd.Process == nil {
-		slog.Info("OpenCode not running, nothing to stop")
+		oc.log.Info("OpenCode not running, nothing to stop")
 		return nil
 	}
 
 	pid := oc.cmd.Process.Pid
-	slog.Info("Stopping OpenCode", "pid", pid)
-	if err := oc.cmd.Process.Kill(); err != nil {
+	timeout := oc.config.StopTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	oc.log.Info("Stopping OpenCode", "pid", pid, "timeout", timeout)
+	// Children that left the process group are only reachable while their
+	// parent is still alive
+	tree := descendants(pid)
+	if err := terminateProcess(oc.cmd.Process); err != nil {
+		oc.log.Warn("Failed to terminate OpenCode, killing it", "pid", pid, "err", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-oc.exited:
+	case <-timer.C:
+		oc.log.Warn("OpenCode did not exit in time, killing it", "pid", pid)
+	case <-ctx.Done():
+		oc.log.Warn("Stop cancelled, killing OpenCode", "pid", pid)
+	}
+	if err := killProcess(oc.cmd.Process); err != nil {
 		return fmt.Errorf("failed to stop opencode: %w", err)
 	}
+	killPIDs(tree)
+	<-oc.exited
 
 	oc.cmd = nil
-	slog.Info("OpenCode stopped", "pid", pid)
+	oc.log.Info("OpenCode stopped", "pid", pid)
 	return nil
 }
 
+// dialHost returns the host clients should connect to for a server bound to
+// hostname, using loopback for wildcard bindings.
+func dialHost(hostname string) string {
+	if ip := net.ParseIP(hostname); ip != nil && ip.IsUnspecified() {
+		if ip.To4() == nil {
+			return "::1"
+		}
+		return "127.0.0.1"
+	}
+	return hostname
+}
+
+// Logger returns the logger the client logs through, which redacts secrets
+// and content unless Config.LogVerbose is set. Packages built on the client
+// should log through it too.
+func (oc *OpenCode) Logger() *slog.Logger {
+	return oc.log
+}
+
 func (oc *OpenCode) Addr() string {
-	return oc.config.Addr
+	oc.addrMu.RLock()
+	defer oc.addrMu.RUnlock()
+	return oc.addr
 }
 
-func (oc *OpenCode) WaitForReady(ctx context.Context, maybeTimeout ...time.Duration) error {
+// WaitForReady polls /global/health until the server answers. It fails with
+// ErrNotReady after the timeout, or as soon as a process started by Start
+// exits, wrapping an *ExitError with its stderr.
+func (oc *OpenCode) WaitForReady(ctx context.Context, maybeTimeout ...time.Duration) (err error) {
+	ctx, span := oc.startSpan(ctx, "opencode.WaitForReady")
+	defer func() { span.End(err) }()
 	timeout := 15 * time.Second
 	if len(maybeTimeout) > 0 {
 		timeout = maybeTimeout[0]
@@ -168,11 +654,16 @@ func (oc *OpenCode) WaitForReady(ctx context.Context, maybeTimeout ...time.Durat
 	if len(maybeTimeout) > 0 {
 		ctx, cancel = context.WithTimeout(ctx, timeout)
 	} else {
-		cancel = func() {}
+		ctx, cancel = context.WithCancel(ctx)
 	}
+	// Stops the poller when the process exits first
 	defer cancel()
-	slog.Info("Waiting for OpenCode to be ready", "addr", oc.config.Addr, "timeout", timeout)
-	readyChan := make(chan struct{})
+	oc.log.Debug("Waiting for OpenCode to be ready", "addr", oc.Addr(), "timeout", timeout)
+	// Nil for servers not started by Start, which never report an exit
+	oc.exitMu.Lock()
+	exited := oc.exited
+	oc.exitMu.Unlock()
+	readyChan := make(chan struct{}, 1)
 	go func() {
 		ticker := time.NewTicker(500 * time.Millisecond)
 		defer ticker.Stop()
@@ -181,16 +672,16 @@ func (oc *OpenCode) WaitForReady(ctx context.Context, maybeTimeout ...time.Durat
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				req, _ := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/global/health", oc.config.Addr), nil)
-				resp, err := http.DefaultClient.Do(req)
+				req, _ := oc.newRequest(ctx, http.MethodGet, "/global/health", nil)
+				resp, err := oc.sendWith(oc.client, req)
 				if err == nil {
 					resp.Body.Close()
-					slog.Info("OpenCode is ready", "addr", oc.config.Addr, "attempt", i+1)
+					oc.log.Info("OpenCode is ready", "addr", oc.Addr(), "attempt", i+1)
 					readyChan <- struct{}{}
 					return
 				}
 				if i%10 == 0 {
-					slog.Debug("Waiting for OpenCode...", "attempt", i+1, "err", err)
+					oc.log.Debug("Waiting for OpenCode...", "attempt", i+1, "err", err)
 				}
 			}
 		}
@@ -198,8 +689,15 @@ func (oc *OpenCode) WaitForReady(ctx context.Context, maybeTimeout ...time.Durat
 	select {
 	case <-readyChan:
 		return nil
+	case <-exited:
+		state := oc.ExitState()
+		if state == nil {
+			// A supervisor restart already replaced the process
+			return fmt.Errorf("%w: opencode exited", ErrNotReady)
+		}
+		return fmt.Errorf("%w: %w", ErrNotReady, &ExitError{ExitState: *state})
 	case <-ctx.Done():
-		return fmt.Errorf("opencode is not ready after %s", timeout)
+		return fmt.Errorf("%w after %s", ErrNotReady, timeout)
 	}
 }
 
@@ -210,13 +708,53 @@ func (oc *OpenCode) Cleanup() error {
 	if oc.configDir == "" {
 		return nil
 	}
+	if !oc.ownsConfigDir {
+		oc.configDir = ""
+		return nil
+	}
 
-	slog.Info("Cleaning up config directory", "path", oc.configDir)
+	oc.log.Debug("Cleaning up config directory", "path", oc.configDir)
 	if err := os.RemoveAll(oc.configDir); err != nil {
 		return fmt.Errorf("failed to remove config directory: %w", err)
 	}
 
 	oc.configDir = ""
-	slog.Info("Config directory removed")
+	oc.ownsConfigDir = false
+	oc.log.Debug("Config directory removed")
 	return nil
 }
+
+// Close ends all event streams and subscriptions, stops the server and
+// removes the temporary config directory. Event streams return ErrClosed
+// afterwards. When ctx is done, Close stops waiting for streams and kills the
+// server without waiting for a graceful exit.
+func (oc *OpenCode) Close(ctx context.Context) error {
+	oc.streamsMu.Lock()
+	oc.closeStreams()
+	oc.streamsMu.Unlock()
+
+	streamsDone := make(chan struct{})
+	go func() {
+		oc.streams.Wait()
+		close(streamsDone)
+	}()
+	select {
+	case <-streamsDone:
+	case <-ctx.Done():
+		oc.log.Warn("Event streams did not end before Close was cancelled")
+	}
+
+	return errors.Join(oc.Stop(ctx), oc.Cleanup())
+}
+
+// trackStream registers an event stream for Close to wait on. It returns
+// false once Close has been called.
+func (oc *OpenCode) trackStream() (done func(), ok bool) {
+	oc.streamsMu.Lock()
+	defer oc.streamsMu.Unlock()
+	if oc.closeCtx.Err() != nil {
+		return nil, false
+	}
+	oc.streams.Add(1)
+	return oc.streams.Done, true
+}
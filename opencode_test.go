@@ -1,6 +1,8 @@
 package opencode
 
 import (
+	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,7 +24,7 @@ func TestNew(t *testing.T) {
 
 func TestStopWhenNotRunning(t *testing.T) {
 	oc := New(Config{})
-	err := oc.Stop()
+	err := oc.Stop(context.Background())
 	assert.NoError(t, err)
 }
 
@@ -48,7 +50,38 @@ func TestStartAutoAllocatesPort(t *testing.T) {
 
 	err := oc.Start()
 	if err == nil {
-		oc.Stop()
+		oc.Stop(context.Background())
 		t.Log("Started successfully, would allocate random port")
 	}
 }
+
+func TestStartRefusesSocket(t *testing.T) {
+	oc := New(Config{Socket: "/tmp/opencode.sock"})
+	assert.Error(t, oc.Start())
+}
+
+func TestDialHost(t *testing.T) {
+	assert.Equal(t, "127.0.0.1", dialHost("0.0.0.0"))
+	assert.Equal(t, "::1", dialHost("::"))
+	assert.Equal(t, "10.0.0.5", dialHost("10.0.0.5"))
+	assert.Equal(t, "localhost", dialHost("localhost"))
+}
+
+func TestArgs(t *testing.T) {
+	oc := New(Config{})
+	assert.Equal(t, []string{"serve", "--hostname", "127.0.0.1", "--port", "4096"}, oc.args("127.0.0.1", 4096))
+
+	oc = New(Config{LogLevel: LogLevelDebug, ExtraArgs: []string{"--print-logs"}})
+	assert.Equal(t, []string{"serve", "--hostname", "0.0.0.0", "--port", "80", "--log-level", "DEBUG", "--print-logs"}, oc.args("0.0.0.0", 80))
+}
+
+func TestStartBinaryNotFound(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	oc := New(Config{})
+	assert.ErrorIs(t, oc.Start(), ErrBinaryNotFound)
+
+	oc = New(Config{}, WithBinary(filepath.Join(t.TempDir(), "opencode")))
+	err := oc.Start()
+	assert.ErrorIs(t, err, ErrBinaryNotFound)
+	assert.Nil(t, oc.cmd)
+}
@@ -0,0 +1,250 @@
+// Package opencodeconfig describes opencode's config.json in Go types so
+// configs are checked at compile time instead of failing at server startup.
+package opencodeconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const SchemaURL = "https://opencode.ai/config.json"
+
+type Config struct {
+	Schema string `json:"$schema,omitempty"`
+	// Model is the default model as "provider/model".
+	Model      string `json:"model,omitempty"`
+	SmallModel string `json:"small_model,omitempty"`
+	Theme      string `json:"theme,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Autoupdate *bool  `json:"autoupdate,omitempty"`
+	Share      Share  `json:"share,omitempty"`
+	// Instructions are paths or globs of files added to the system prompt.
+	Instructions      []string            `json:"instructions,omitempty"`
+	Provider          map[string]Provider `json:"provider,omitempty"`
+	DisabledProviders []string            `json:"disabled_providers,omitempty"`
+	EnabledProviders  []string            `json:"enabled_providers,omitempty"`
+	Agent             map[string]Agent    `json:"agent,omitempty"`
+	Command           map[string]Command  `json:"command,omitempty"`
+	MCP               map[string]MCP      `json:"mcp,omitempty"`
+	Permission        *Permission         `json:"permission,omitempty"`
+	// Tools enables or disables tools by name for all agents.
+	Tools map[string]bool `json:"tools,omitempty"`
+}
+
+type Share string
+
+const (
+	ShareManual   Share = "manual"
+	ShareAuto     Share = "auto"
+	ShareDisabled Share = "disabled"
+)
+
+type Provider struct {
+	// NPM is the AI SDK package for custom providers, e.g.
+	// "@ai-sdk/openai-compatible".
+	NPM     string           `json:"npm,omitempty"`
+	Name    string           `json:"name,omitempty"`
+	Options *ProviderOptions `json:"options,omitempty"`
+	Models  map[string]Model `json:"models,omitempty"`
+}
+
+type ProviderOptions struct {
+	APIKey  string `json:"apiKey,omitempty"`
+	BaseURL string `json:"baseURL,omitempty"`
+	// Timeout is the request timeout in milliseconds.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+type Model struct {
+	Name  string      `json:"name,omitempty"`
+	Limit *ModelLimit `json:"limit,omitempty"`
+}
+
+type ModelLimit struct {
+	Context int `json:"context,omitempty"`
+	Output  int `json:"output,omitempty"`
+}
+
+type AgentMode string
+
+const (
+	AgentPrimary  AgentMode = "primary"
+	AgentSubagent AgentMode = "subagent"
+	AgentAll      AgentMode = "all"
+)
+
+type Agent struct {
+	Description string          `json:"description,omitempty"`
+	Mode        AgentMode       `json:"mode,omitempty"`
+	Model       string          `json:"model,omitempty"`
+	Prompt      string          `json:"prompt,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	Tools       map[string]bool `json:"tools,omitempty"`
+	Permission  *Permission     `json:"permission,omitempty"`
+	Disable     bool            `json:"disable,omitempty"`
+}
+
+type Command struct {
+	Template    string `json:"template"`
+	Description string `json:"description,omitempty"`
+	Agent       string `json:"agent,omitempty"`
+	Model       string `json:"model,omitempty"`
+}
+
+type MCPType string
+
+const (
+	MCPLocal  MCPType = "local"
+	MCPRemote MCPType = "remote"
+)
+
+type MCP struct {
+	Type MCPType `json:"type"`
+	// Command and Environment configure local servers.
+	Command     []string          `json:"command,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+	// URL and Headers configure remote servers.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Enabled *bool             `json:"enabled,omitempty"`
+}
+
+func LocalMCP(command ...string) MCP {
+	return MCP{Type: MCPLocal, Command: command}
+}
+
+func RemoteMCP(url string) MCP {
+	return MCP{Type: MCPRemote, URL: url}
+}
+
+type PermissionLevel string
+
+const (
+	Ask   PermissionLevel = "ask"
+	Allow PermissionLevel = "allow"
+	Deny  PermissionLevel = "deny"
+)
+
+type Permission struct {
+	Edit PermissionLevel `json:"edit,omitempty"`
+	// Bash maps command patterns such as "git push*" or "*" to levels.
+	Bash     map[string]PermissionLevel `json:"bash,omitempty"`
+	WebFetch PermissionLevel            `json:"webfetch,omitempty"`
+}
+
+// UnmarshalJSON also accepts the shorthand "bash": "allow" for all commands.
+func (p *Permission) UnmarshalJSON(data []byte) error {
+	type plain Permission
+	var raw struct {
+		plain
+		Bash json.RawMessage `json:"bash,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Permission(raw.plain)
+	if len(raw.Bash) == 0 {
+		return nil
+	}
+	var level PermissionLevel
+	if err := json.Unmarshal(raw.Bash, &level); err == nil {
+		p.Bash = map[string]PermissionLevel{"*": level}
+		return nil
+	}
+	return json.Unmarshal(raw.Bash, &p.Bash)
+}
+
+// JSON validates the config and encodes it as config.json.
+func (c *Config) JSON() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	out := *c
+	if out.Schema == "" {
+		out.Schema = SchemaURL
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// FieldError is one problem found by Validate.
+type FieldError struct {
+	// Field is the dotted config key, e.g. "agent.review.mode".
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Validate reports every problem the type system cannot catch, such as model
+// IDs without a provider or MCP servers missing their command. The returned
+// error joins one *FieldError per problem.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			errs = append(errs, &FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	checkModel := func(field, model string) {
+		check(model == "" || validModel(model), field, "%q must be provider/model", model)
+	}
+
+	checkModel("model", c.Model)
+	checkModel("small_model", c.SmallModel)
+	check(c.Share == "" || c.Share == ShareManual || c.Share == ShareAuto || c.Share == ShareDisabled, "share", "%q must be manual, auto or disabled", c.Share)
+	for _, name := range slices.Sorted(maps.Keys(c.Agent)) {
+		agent := c.Agent[name]
+		checkModel("agent."+name+".model", agent.Model)
+		check(agent.Mode == "" || agent.Mode == AgentPrimary || agent.Mode == AgentSubagent || agent.Mode == AgentAll, "agent."+name+".mode", "%q must be primary, subagent or all", agent.Mode)
+		if agent.Permission != nil {
+			errs = append(errs, agent.Permission.validate("agent."+name+".")...)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Command)) {
+		command := c.Command[name]
+		check(command.Template != "", "command."+name+".template", "is required")
+		checkModel("command."+name+".model", command.Model)
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.MCP)) {
+		mcp := c.MCP[name]
+		switch mcp.Type {
+		case MCPLocal:
+			check(len(mcp.Command) > 0, "mcp."+name+".command", "is required for local servers")
+		case MCPRemote:
+			check(mcp.URL != "", "mcp."+name+".url", "is required for remote servers")
+		default:
+			check(false, "mcp."+name+".type", "%q must be local or remote", mcp.Type)
+		}
+	}
+	if c.Permission != nil {
+		errs = append(errs, c.Permission.validate("")...)
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Permission) validate(prefix string) []error {
+	var errs []error
+	check := func(field string, level PermissionLevel) {
+		if level != "" && level != Ask && level != Allow && level != Deny {
+			errs = append(errs, &FieldError{Field: prefix + "permission." + field, Message: fmt.Sprintf("%q must be ask, allow or deny", level)})
+		}
+	}
+	check("edit", p.Edit)
+	check("webfetch", p.WebFetch)
+	for _, pattern := range slices.Sorted(maps.Keys(p.Bash)) {
+		check("bash."+pattern, p.Bash[pattern])
+	}
+	return errs
+}
+
+func validModel(id string) bool {
+	provider, model, ok := strings.Cut(id, "/")
+	return ok && provider != "" && model != ""
+}
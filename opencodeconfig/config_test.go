@@ -0,0 +1,79 @@
+package opencodeconfig
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	temperature := 0.2
+	cfg := &Config{
+		Model:        "anthropic/claude-sonnet-4-5",
+		Instructions: []string{"AGENTS.md"},
+		Provider: map[string]Provider{
+			"local": {
+				NPM:     "@ai-sdk/openai-compatible",
+				Options: &ProviderOptions{BaseURL: "http://localhost:11434/v1"},
+				Models:  map[string]Model{"qwen": {Name: "Qwen"}},
+			},
+		},
+		Agent: map[string]Agent{
+			"review": {Mode: AgentSubagent, Temperature: &temperature, Tools: map[string]bool{"write": false}},
+		},
+		MCP:        map[string]MCP{"fs": LocalMCP("npx", "-y", "mcp-fs")},
+		Permission: &Permission{Edit: Ask, Bash: map[string]PermissionLevel{"*": Allow, "git push*": Deny}},
+	}
+
+	data, err := cfg.JSON()
+	require.NoError(t, err)
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, SchemaURL, decoded["$schema"])
+	assert.Equal(t, "anthropic/claude-sonnet-4-5", decoded["model"])
+	assert.Equal(t, "http://localhost:11434/v1", decoded["provider"].(map[string]any)["local"].(map[string]any)["options"].(map[string]any)["baseURL"])
+	assert.Equal(t, map[string]any{"type": "local", "command": []any{"npx", "-y", "mcp-fs"}}, decoded["mcp"].(map[string]any)["fs"])
+	assert.Equal(t, map[string]any{"mode": "subagent", "temperature": 0.2, "tools": map[string]any{"write": false}}, decoded["agent"].(map[string]any)["review"])
+	assert.Equal(t, "deny", decoded["permission"].(map[string]any)["bash"].(map[string]any)["git push*"])
+	assert.Empty(t, cfg.Schema, "JSON must not modify the config")
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &Config{
+		Model:      "claude-sonnet",
+		Agent:      map[string]Agent{"plan": {Mode: "main"}},
+		Command:    map[string]Command{"test": {}},
+		MCP:        map[string]MCP{"remote": {Type: MCPRemote}, "odd": {Type: "stdio"}},
+		Permission: &Permission{Edit: "yes"},
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, want := range []string{
+		`model: "claude-sonnet" must be provider/model`,
+		`agent.plan.mode: "main" must be primary, subagent or all`,
+		`command.test.template: is required`,
+		`mcp.remote.url: is required for remote servers`,
+		`mcp.odd.type: "stdio" must be local or remote`,
+		`permission.edit: "yes" must be ask, allow or deny`,
+	} {
+		assert.ErrorContains(t, err, want)
+	}
+	var fieldErr *FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, "model", fieldErr.Field)
+
+	_, err = cfg.JSON()
+	assert.Error(t, err)
+	assert.NoError(t, (&Config{}).Validate())
+}
+
+func TestPermissionBashShorthand(t *testing.T) {
+	var p Permission
+	require.NoError(t, json.Unmarshal([]byte(`{"edit":"deny","bash":"ask"}`), &p))
+	assert.Equal(t, Permission{Edit: Deny, Bash: map[string]PermissionLevel{"*": Ask}}, p)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"bash":{"git *":"allow"}}`), &p))
+	assert.Equal(t, map[string]PermissionLevel{"git *": Allow}, p.Bash)
+}
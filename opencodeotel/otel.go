@@ -0,0 +1,84 @@
+// Package opencodeotel traces opencode clients with OpenTelemetry.
+package opencodeotel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ai-shift/opencode"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ai-shift/opencode"
+
+// Tracer implements opencode.Tracer. Set it as Config.Tracer.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ opencode.Tracer = (*Tracer)(nil)
+
+// New creates a Tracer from tp, or the global TracerProvider if tp is nil.
+// Trace context is propagated with the global propagator.
+func New(tp trace.TracerProvider) *Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &Tracer{
+		tracer:     tp.Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+}
+
+func (t *Tracer) StartSpan(ctx context.Context, name string) (context.Context, opencode.Span) {
+	// Requests are named after their method and route, the client's own
+	// operations start with "opencode."
+	kind := trace.SpanKindClient
+	if strings.HasPrefix(name, "opencode.") {
+		kind = trace.SpanKindInternal
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, otelSpan{span}
+}
+
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttribute(key string, value any) {
+	var kv attribute.KeyValue
+	switch v := value.(type) {
+	case string:
+		kv = attribute.String(key, v)
+	case int:
+		kv = attribute.Int(key, v)
+	case int64:
+		kv = attribute.Int64(key, v)
+	case float64:
+		kv = attribute.Float64(key, v)
+	case bool:
+		kv = attribute.Bool(key, v)
+	default:
+		kv = attribute.String(key, fmt.Sprint(v))
+	}
+	s.span.SetAttributes(kv)
+}
+
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,46 @@
+package opencodeotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ai-shift/opencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant","time":{"created":1,"completed":2},"finish":"stop"},"parts":[]}`))
+	}))
+	defer srv.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	oc := opencode.New(opencode.Config{Addr: strings.TrimPrefix(srv.URL, "http://"), Tracer: New(tp)})
+
+	_, err := oc.SendMessage(context.Background(), "ses_1", opencode.Text("hi"))
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	request, send := spans[0], spans[1]
+	assert.Equal(t, "POST /session/:id/message", request.Name())
+	assert.Equal(t, trace.SpanKindClient, request.SpanKind())
+	assert.Equal(t, "opencode.SendMessage", send.Name())
+	assert.Equal(t, send.SpanContext().SpanID(), request.Parent().SpanID())
+	assert.Contains(t, traceparent, request.SpanContext().SpanID().String())
+	assert.Contains(t, send.Attributes(), attribute.String("opencode.message.id", "msg_2"))
+}
@@ -0,0 +1,342 @@
+// Package opencodepool runs several opencode servers and leases them out one
+// user at a time, so concurrent users do not share a server's working state.
+package opencodepool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ai-shift/opencode"
+)
+
+var ErrClosed = errors.New("pool closed")
+
+type Config struct {
+	// Size is the number of servers. Defaults to 1.
+	Size int
+	// Instance configures every server. Each one gets its own ConfigDir and
+	// DataDir below Dir and a random port. AutoRestart is ignored, since the
+	// pool replaces crashed servers itself.
+	Instance opencode.Config
+	// Options are passed to opencode.New for every server.
+	Options []opencode.Option
+	// Dir holds the servers' config and data directories as Dir/<n>/config
+	// and Dir/<n>/data, so a replacement keeps the sessions of the server it
+	// replaces. Defaults to a temporary directory that Close removes.
+	Dir string
+	// ReadyTimeout bounds the start of each server. Defaults to 15 seconds.
+	ReadyTimeout time.Duration
+	// Health configures the health checks of every server. A server that
+	// becomes unhealthy is replaced after OnUnhealthy is called.
+	Health opencode.HealthPolicy
+	// OnReplace is called with the cause after a crashed or unhealthy
+	// server was replaced.
+	OnReplace func(index int, cause error)
+}
+
+// Pool keeps Config.Size servers running and hands each one to a single user
+// at a time.
+type Pool struct {
+	cfg     Config
+	dir     string
+	ownsDir bool
+	log     *slog.Logger
+
+	// ctx is cancelled by Close to stop health checks and replacements,
+	// which are tracked in wg
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	members []*member
+	idle    []*member
+	// changed is closed and replaced whenever a member becomes idle or the
+	// pool closes, waking up Acquire
+	changed chan struct{}
+	closed  bool
+}
+
+type member struct {
+	index     int
+	oc        *opencode.OpenCode
+	stop      context.CancelFunc
+	unhealthy chan error
+	// dead is set once the member is being replaced, guarded by Pool.mu
+	dead bool
+}
+
+// Lease is a server handed out by Acquire.
+type Lease struct {
+	pool   *Pool
+	member *member
+	once   sync.Once
+}
+
+// New starts the servers and waits until all of them are ready. If one fails
+// to start, the others are stopped again.
+func New(ctx context.Context, cfg Config) (*Pool, error) {
+	if cfg.Size <= 0 {
+		cfg.Size = 1
+	}
+	p := &Pool{
+		cfg:     cfg,
+		dir:     cfg.Dir,
+		log:     cfg.Instance.Logger,
+		members: make([]*member, cfg.Size),
+		changed: make(chan struct{}),
+	}
+	if p.log == nil {
+		p.log = slog.Default()
+	}
+	if p.dir == "" {
+		dir, err := os.MkdirTemp(cfg.Instance.TempDir, "opencodepool-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create pool directory: %w", err)
+		}
+		p.dir, p.ownsDir = dir, true
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, p.cancel)
+	defer stop()
+
+	errs := make([]error, cfg.Size)
+	var wg sync.WaitGroup
+	for i := range cfg.Size {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.members[i], errs[i] = p.start(i)
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, errors.Join(err, p.Close(context.Background()))
+	}
+	p.idle = append(p.idle, p.members...)
+	p.log.Info("Started opencode pool", "size", cfg.Size, "dir", p.dir)
+	return p, nil
+}
+
+func (p *Pool) start(index int) (*member, error) {
+	dir := filepath.Join(p.dir, strconv.Itoa(index))
+	cfg := p.cfg.Instance
+	cfg.ConfigDir = filepath.Join(dir, "config")
+	cfg.DataDir = filepath.Join(dir, "data")
+	cfg.Port = 0
+	cfg.AutoRestart = nil
+	if err := os.MkdirAll(cfg.ConfigDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create config directory of instance %d: %w", index, err)
+	}
+
+	oc := opencode.New(cfg, p.cfg.Options...)
+	if err := oc.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start instance %d: %w", index, errors.Join(err, oc.Cleanup()))
+	}
+	timeout := p.cfg.ReadyTimeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	if err := oc.WaitForReady(p.ctx, timeout); err != nil {
+		return nil, fmt.Errorf("failed to start instance %d: %w", index, errors.Join(err, oc.Close(context.Background())))
+	}
+
+	ctx, stop := context.WithCancel(p.ctx)
+	m := &member{index: index, oc: oc, stop: stop, unhealthy: make(chan error, 1)}
+	policy := p.cfg.Health
+	policy.OnUnhealthy = func(err error) {
+		if p.cfg.Health.OnUnhealthy != nil {
+			p.cfg.Health.OnUnhealthy(err)
+		}
+		select {
+		case m.unhealthy <- err:
+		default:
+		}
+	}
+	oc.MonitorHealth(ctx, policy)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		p.watch(ctx, m)
+	}()
+	return m, nil
+}
+
+// watch replaces m once its process exits or it becomes unhealthy.
+func (p *Pool) watch(ctx context.Context, m *member) {
+	var cause error
+	select {
+	case <-ctx.Done():
+		return
+	case err := <-m.oc.Done():
+		cause = fmt.Errorf("opencode exited: %w", err)
+		if err == nil {
+			cause = errors.New("opencode exited")
+		}
+	case err := <-m.unhealthy:
+		cause = fmt.Errorf("opencode is unhealthy: %w", err)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	p.replace(m, cause)
+}
+
+func (p *Pool) replace(old *member, cause error) {
+	p.mu.Lock()
+	if p.closed || old.dead {
+		p.mu.Unlock()
+		return
+	}
+	old.dead = true
+	for i, m := range p.idle {
+		if m == old {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	p.log.Warn("Replacing opencode instance", "index", old.index, "addr", old.oc.Addr(), "cause", cause)
+	old.close(p.ctx)
+	backoff := time.Second
+	for {
+		m, err := p.start(old.index)
+		if err == nil {
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				m.close(context.Background())
+				return
+			}
+			p.members[old.index] = m
+			p.release(m)
+			p.mu.Unlock()
+			p.log.Info("Replaced opencode instance", "index", old.index, "addr", m.oc.Addr())
+			if p.cfg.OnReplace != nil {
+				p.cfg.OnReplace(old.index, cause)
+			}
+			return
+		}
+		p.log.Warn("Failed to replace opencode instance", "index", old.index, "err", err, "retry", backoff)
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+func (m *member) close(ctx context.Context) error {
+	m.stop()
+	return m.oc.Close(ctx)
+}
+
+// release makes m available to Acquire. The caller holds p.mu.
+func (p *Pool) release(m *member) {
+	p.idle = append(p.idle, m)
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// Acquire waits until a server is free and leases it. Call Release on the
+// lease when done with it.
+func (p *Pool) Acquire(ctx context.Context) (*Lease, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrClosed
+		}
+		if n := len(p.idle); n > 0 {
+			m := p.idle[n-1]
+			p.idle = p.idle[:n-1]
+			p.mu.Unlock()
+			return &Lease{pool: p, member: m}, nil
+		}
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Client returns the leased server. If it crashes while leased, the pool
+// replaces it right away and the client keeps failing until released.
+func (l *Lease) Client() *opencode.OpenCode {
+	return l.member.oc
+}
+
+// Release returns the server to the pool. Further calls do nothing.
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		p := l.pool
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.closed && !l.member.dead {
+			p.release(l.member)
+		}
+	})
+}
+
+// Size returns the number of servers in the pool.
+func (p *Pool) Size() int {
+	return p.cfg.Size
+}
+
+// Idle returns the number of servers that are not leased.
+func (p *Pool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close stops all servers, including leased ones, and removes the pool
+// directory unless Config.Dir was set. Waiting Acquire calls return
+// ErrClosed.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	members := p.members
+	close(p.changed)
+	p.mu.Unlock()
+
+	p.cancel()
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		if m == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.close(ctx)
+		}()
+	}
+	wg.Wait()
+	p.wg.Wait()
+
+	if p.ownsDir {
+		if err := os.RemoveAll(p.dir); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove pool directory: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
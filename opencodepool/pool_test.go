@@ -0,0 +1,106 @@
+//go:build unix
+
+package opencodepool
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ai-shift/opencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("OPENCODE_FAKE_SERVER") != "" {
+		fakeServerMain()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServerMain stands in for "opencode serve". The launch that creates
+// OPENCODE_FAKE_STATE crashes shortly after becoming ready.
+func fakeServerMain() {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	hostname := flags.String("hostname", "127.0.0.1", "")
+	port := flags.Int("port", 0, "")
+	flags.Parse(os.Args[2:])
+
+	if f, err := os.OpenFile(os.Getenv("OPENCODE_FAKE_STATE"), os.O_CREATE|os.O_EXCL, 0644); err == nil {
+		f.Close()
+		time.AfterFunc(time.Second, func() { os.Exit(1) })
+	}
+
+	http.HandleFunc("/global/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"healthy":true}`))
+	})
+	http.ListenAndServe(fmt.Sprintf("%s:%d", *hostname, *port), nil)
+}
+
+func fakeServer(t *testing.T) {
+	t.Helper()
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	t.Setenv("OPENCODE_FAKE_SERVER", "1")
+	t.Setenv("OPENCODE_FAKE_STATE", filepath.Join(t.TempDir(), "crashed"))
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nexec %q \"$@\"\n", exe)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "opencode"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestPool(t *testing.T) {
+	fakeServer(t)
+	replaced := make(chan int, 1)
+	dir := t.TempDir()
+	pool, err := New(context.Background(), Config{
+		Size:      2,
+		Dir:       dir,
+		Instance:  opencode.Config{StopTimeout: time.Second},
+		OnReplace: func(index int, cause error) { replaced <- index },
+	})
+	require.NoError(t, err)
+	defer pool.Close(context.Background())
+	assert.DirExists(t, filepath.Join(dir, "0", "config"))
+	assert.DirExists(t, filepath.Join(dir, "1", "data"))
+
+	a, err := pool.Acquire(context.Background())
+	require.NoError(t, err)
+	b, err := pool.Acquire(context.Background())
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Client().Addr(), b.Client().Addr())
+	assert.Equal(t, 0, pool.Idle())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The first server crashes and is replaced while leased.
+	select {
+	case <-replaced:
+	case <-time.After(10 * time.Second):
+		t.Fatal("crashed instance not replaced")
+	}
+	assert.Equal(t, 1, pool.Idle())
+	a.Release()
+	b.Release()
+	a.Release()
+	assert.Equal(t, 2, pool.Idle())
+
+	lease, err := pool.Acquire(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, lease.Client().WaitForReady(context.Background(), time.Second))
+	lease.Release()
+
+	require.NoError(t, pool.Close(context.Background()))
+	_, err = pool.Acquire(context.Background())
+	assert.ErrorIs(t, err, ErrClosed)
+}
@@ -0,0 +1,131 @@
+// Package opencodeprom exports opencode client metrics to Prometheus.
+package opencodeprom
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ai-shift/opencode"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics implements opencode.Metrics. Set it as Config.Metrics; to tell
+// several clients apart, register each through prometheus.WrapRegistererWith.
+type Metrics struct {
+	requests   *prometheus.CounterVec
+	latency    *prometheus.HistogramVec
+	events     *prometheus.CounterVec
+	reconnects prometheus.Counter
+	restarts   prometheus.Counter
+	tokens     *prometheus.CounterVec
+	cost       *prometheus.CounterVec
+
+	mu sync.Mutex
+	// counted holds the completed assistant messages per session, since
+	// every open event stream reports them and updates repeat
+	counted map[string]map[string]struct{}
+}
+
+var _ opencode.Metrics = (*Metrics)(nil)
+
+// New creates the metrics and registers them with reg. Token and cost totals
+// are labelled by session and dropped when the session is deleted.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opencode_requests_total",
+			Help: "API requests by method, route and status code, zero for transport errors.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "opencode_request_duration_seconds",
+			Help:    "Time until the response headers of API requests arrived.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 10),
+		}, []string{"method", "route"}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opencode_events_total",
+			Help: "Events received on event streams by type.",
+		}, []string{"type"}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "opencode_event_stream_reconnects_total",
+			Help: "Event stream reconnects.",
+		}),
+		restarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "opencode_restarts_total",
+			Help: "Server restarts by Restart or AutoRestart.",
+		}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opencode_session_tokens_total",
+			Help: "Tokens used by completed assistant messages by session and kind.",
+		}, []string{"session", "kind"}),
+		cost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "opencode_session_cost_dollars_total",
+			Help: "Cost of completed assistant messages by session.",
+		}, []string{"session"}),
+		counted: make(map[string]map[string]struct{}),
+	}
+	for _, c := range []prometheus.Collector{m.requests, m.latency, m.events, m.reconnects, m.restarts, m.tokens, m.cost} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.latency.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (m *Metrics) ObserveEvent(event opencode.Event) {
+	m.events.WithLabelValues(event.EventType()).Inc()
+	switch e := event.(type) {
+	case *opencode.MessageUpdatedEvent:
+		m.observeMessage(e.Info)
+	case *opencode.SessionDeletedEvent:
+		m.mu.Lock()
+		delete(m.counted, e.Info.ID)
+		m.mu.Unlock()
+		m.tokens.DeletePartialMatch(prometheus.Labels{"session": e.Info.ID})
+		m.cost.DeleteLabelValues(e.Info.ID)
+	}
+}
+
+func (m *Metrics) observeMessage(info opencode.Message) {
+	if info.Role != opencode.RoleAssistant || info.Time.Completed == 0 {
+		return
+	}
+	m.mu.Lock()
+	counted := m.counted[info.SessionID]
+	if counted == nil {
+		counted = make(map[string]struct{})
+		m.counted[info.SessionID] = counted
+	}
+	_, seen := counted[info.ID]
+	counted[info.ID] = struct{}{}
+	m.mu.Unlock()
+	if seen {
+		return
+	}
+
+	if info.Tokens != nil {
+		for kind, n := range map[string]int{
+			"input":       info.Tokens.Input,
+			"output":      info.Tokens.Output,
+			"reasoning":   info.Tokens.Reasoning,
+			"cache_read":  info.Tokens.Cache.Read,
+			"cache_write": info.Tokens.Cache.Write,
+		} {
+			m.tokens.WithLabelValues(info.SessionID, kind).Add(float64(n))
+		}
+	}
+	m.cost.WithLabelValues(info.SessionID).Add(info.Cost)
+}
+
+func (m *Metrics) ObserveReconnect() {
+	m.reconnects.Inc()
+}
+
+func (m *Metrics) ObserveRestart() {
+	m.restarts.Inc()
+}
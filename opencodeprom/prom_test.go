@@ -0,0 +1,45 @@
+package opencodeprom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ai-shift/opencode"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := New(reg)
+	require.NoError(t, err)
+
+	m.ObserveRequest("GET", "/session/:id", 200, 20*time.Millisecond)
+	m.ObserveRequest("GET", "/session/:id", 200, 30*time.Millisecond)
+	m.ObserveReconnect()
+	m.ObserveRestart()
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET", "/session/:id", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.reconnects))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.restarts))
+
+	completed := &opencode.MessageUpdatedEvent{Info: opencode.Message{
+		ID: "msg_1", SessionID: "ses_1", Role: opencode.RoleAssistant,
+		Time:   opencode.MessageTime{Created: 1, Completed: 2},
+		Cost:   0.25,
+		Tokens: &opencode.Tokens{Input: 100, Output: 20},
+	}}
+	m.ObserveEvent(&opencode.MessageUpdatedEvent{Info: opencode.Message{ID: "msg_1", SessionID: "ses_1", Role: opencode.RoleAssistant}})
+	m.ObserveEvent(completed)
+	m.ObserveEvent(completed)
+	assert.Equal(t, 3.0, testutil.ToFloat64(m.events.WithLabelValues(opencode.EventMessageUpdated)))
+	assert.Equal(t, 100.0, testutil.ToFloat64(m.tokens.WithLabelValues("ses_1", "input")))
+	assert.Equal(t, 0.25, testutil.ToFloat64(m.cost.WithLabelValues("ses_1")))
+
+	m.ObserveEvent(&opencode.SessionDeletedEvent{Info: opencode.Session{ID: "ses_1"}})
+	assert.Equal(t, 0, testutil.CollectAndCount(m.cost))
+
+	_, err = New(reg)
+	assert.Error(t, err, "metrics are already registered")
+}
@@ -0,0 +1,216 @@
+package opencode
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Part types as in PartBase.Type and Part.PartType.
+const (
+	PartTypeText       = "text"
+	PartTypeReasoning  = "reasoning"
+	PartTypeFile       = "file"
+	PartTypeTool       = "tool"
+	PartTypePatch      = "patch"
+	PartTypeStepStart  = "step-start"
+	PartTypeStepFinish = "step-finish"
+)
+
+type Part interface {
+	PartID() string
+	PartType() string
+	PartSessionID() string
+	PartMessageID() string
+}
+
+type PartBase struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionID"`
+	MessageID string `json:"messageID"`
+	Type      string `json:"type"`
+}
+
+func (p PartBase) PartID() string        { return p.ID }
+func (p PartBase) PartType() string      { return p.Type }
+func (p PartBase) PartSessionID() string { return p.SessionID }
+func (p PartBase) PartMessageID() string { return p.MessageID }
+
+type PartTime struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end,omitempty"`
+}
+
+type TextPart struct {
+	PartBase
+	Text      string    `json:"text"`
+	Synthetic bool      `json:"synthetic,omitempty"`
+	Time      *PartTime `json:"time,omitempty"`
+}
+
+type ReasoningPart struct {
+	PartBase
+	Text string    `json:"text"`
+	Time *PartTime `json:"time,omitempty"`
+}
+
+type FilePart struct {
+	PartBase
+	Mime     string `json:"mime"`
+	Filename string `json:"filename,omitempty"`
+	URL      string `json:"url"`
+}
+
+type ToolStatus string
+
+const (
+	ToolPending   ToolStatus = "pending"
+	ToolRunning   ToolStatus = "running"
+	ToolCompleted ToolStatus = "completed"
+	ToolError     ToolStatus = "error"
+)
+
+// ToolPart flattens the server's nested tool state so the call's progress,
+// arguments and result are directly accessible.
+type ToolPart struct {
+	PartBase
+	CallID   string         `json:"callID"`
+	Tool     string         `json:"tool"`
+	State    ToolStatus     `json:"-"`
+	Input    map[string]any `json:"-"`
+	Output   string         `json:"-"`
+	Error    string         `json:"-"`
+	Title    string         `json:"-"`
+	Metadata map[string]any `json:"-"`
+	Time     *PartTime      `json:"-"`
+}
+
+type toolState struct {
+	Status   ToolStatus     `json:"status"`
+	Input    map[string]any `json:"input,omitempty"`
+	Output   string         `json:"output,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Title    string         `json:"title,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+	Time     *PartTime      `json:"time,omitempty"`
+}
+
+func (p *ToolPart) UnmarshalJSON(data []byte) error {
+	type plain ToolPart
+	var raw struct {
+		plain
+		State toolState `json:"state"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = ToolPart(raw.plain)
+	p.State = raw.State.Status
+	p.Input = raw.State.Input
+	p.Output = raw.State.Output
+	p.Error = raw.State.Error
+	p.Title = raw.State.Title
+	p.Metadata = raw.State.Metadata
+	p.Time = raw.State.Time
+	return nil
+}
+
+func (p ToolPart) MarshalJSON() ([]byte, error) {
+	type plain ToolPart
+	return json.Marshal(struct {
+		plain
+		State toolState `json:"state"`
+	}{
+		plain: plain(p),
+		State: toolState{
+			Status:   p.State,
+			Input:    p.Input,
+			Output:   p.Output,
+			Error:    p.Error,
+			Title:    p.Title,
+			Metadata: p.Metadata,
+			Time:     p.Time,
+		},
+	})
+}
+
+// Diff parses the unified diff that file-editing tools attach to their
+// metadata. It returns nil if the tool produced no diff.
+func (p *ToolPart) Diff() ([]FileDiff, error) {
+	diff, _ := p.Metadata["diff"].(string)
+	if diff == "" {
+		return nil, nil
+	}
+	return ParseDiff(diff)
+}
+
+func (p *ToolPart) Done() bool {
+	return p.State == ToolCompleted || p.State == ToolError
+}
+
+type PatchPart struct {
+	PartBase
+	Hash  string   `json:"hash"`
+	Files []string `json:"files"`
+}
+
+type StepStartPart struct {
+	PartBase
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+type StepFinishPart struct {
+	PartBase
+	Reason   string  `json:"reason,omitempty"`
+	Snapshot string  `json:"snapshot,omitempty"`
+	Cost     float64 `json:"cost"`
+	Tokens   Tokens  `json:"tokens"`
+}
+
+// UnknownPart holds parts of a type this package does not decode yet.
+type UnknownPart struct {
+	PartBase
+	Raw json.RawMessage `json:"-"`
+}
+
+// MarshalJSON writes the part as received.
+func (p UnknownPart) MarshalJSON() ([]byte, error) {
+	if p.Raw == nil {
+		return json.Marshal(p.PartBase)
+	}
+	return p.Raw, nil
+}
+
+func decodePart(data json.RawMessage) (Part, error) {
+	// Only peek at the type; the IDs are decoded with the concrete part
+	var peek struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &peek); err != nil {
+		return nil, fmt.Errorf("failed to decode part: %w", err)
+	}
+
+	var part Part
+	switch peek.Type {
+	case PartTypeText:
+		part = &TextPart{}
+	case PartTypeReasoning:
+		part = &ReasoningPart{}
+	case PartTypeFile:
+		part = &FilePart{}
+	case PartTypeTool:
+		part = &ToolPart{}
+	case PartTypePatch:
+		part = &PatchPart{}
+	case PartTypeStepStart:
+		part = &StepStartPart{}
+	case PartTypeStepFinish:
+		part = &StepFinishPart{}
+	default:
+		part = &UnknownPart{Raw: data}
+	}
+
+	if err := json.Unmarshal(data, part); err != nil {
+		return nil, fmt.Errorf("failed to decode %s part: %w", peek.Type, err)
+	}
+	return part, nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package opencode
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+)
+
+// descendants walks /proc for every process below pid, including those that
+// moved into their own process group or session and so escape a group kill.
+func descendants(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	children := make(map[int][]int)
+	for _, entry := range entries {
+		child, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+		if ppid, ok := statPPID(stat); ok {
+			children[ppid] = append(children[ppid], child)
+		}
+	}
+
+	var pids []int
+	queue := children[pid]
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		pids = append(pids, next)
+		queue = append(queue, children[next]...)
+	}
+	return pids
+}
+
+// statPPID extracts the parent pid from /proc/<pid>/stat. The command name
+// may contain spaces and parentheses, so fields are read after the last ')'.
+func statPPID(stat []byte) (int, bool) {
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(string(fields[1]))
+	return ppid, err == nil
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package opencode
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processAlive treats zombies as dead since nothing may reap orphans in a
+// container.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	i := strings.LastIndexByte(string(stat), ')')
+	return !strings.HasPrefix(strings.TrimSpace(string(stat[i+1:])), "Z")
+}
+
+func readPIDs(t *testing.T, path string, n int) []int {
+	t.Helper()
+	var pids []int
+	require.Eventually(t, func() bool {
+		data, _ := os.ReadFile(path)
+		pids = pids[:0]
+		for _, field := range strings.Fields(string(data)) {
+			pid, err := strconv.Atoi(field)
+			require.NoError(t, err)
+			pids = append(pids, pid)
+		}
+		return len(pids) == n
+	}, 5*time.Second, 20*time.Millisecond)
+	return pids
+}
+
+func TestStopKillsDescendants(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	oc := New(Config{StopTimeout: 200 * time.Millisecond})
+	// A plain child, a grandchild and a child in its own session, the last
+	// of which a process group kill cannot reach
+	launchScript(t, oc, `
+		sleep 300 & echo $! >> `+pidFile+`
+		sh -c 'sleep 300 & echo $! >> `+pidFile+`; wait' &
+		setsid sleep 300 & echo $! >> `+pidFile+`
+		trap "" TERM
+		while :; do sleep 0.05; done`)
+
+	pids := readPIDs(t, pidFile, 3)
+	for _, pid := range pids {
+		assert.Contains(t, descendants(oc.cmd.Process.Pid), pid)
+	}
+
+	require.NoError(t, oc.Stop(context.Background()))
+	for _, pid := range pids {
+		assert.Eventually(t, func() bool { return !processAlive(pid) }, 2*time.Second, 20*time.Millisecond, "pid %d survived Stop", pid)
+	}
+}
+
+func TestStopGracefulKillsDescendants(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pids")
+	oc := New(Config{StopTimeout: 5 * time.Second})
+	launchScript(t, oc, `
+		setsid sleep 300 & echo $! >> `+pidFile+`
+		trap "exit 0" TERM
+		while :; do sleep 0.05; done`)
+
+	pids := readPIDs(t, pidFile, 1)
+	require.NoError(t, oc.Stop(context.Background()))
+	assert.Eventually(t, func() bool { return !processAlive(pids[0]) }, 2*time.Second, 20*time.Millisecond)
+}
+
+func TestStatPPID(t *testing.T) {
+	ppid, ok := statPPID([]byte("42 (we (ird) name) S 7 42 42 0 -1"))
+	assert.True(t, ok)
+	assert.Equal(t, 7, ppid)
+
+	_, ok = statPPID([]byte("garbage"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package opencode
+
+// descendants is only implemented on Linux; elsewhere Stop relies on killing
+// the process group.
+func descendants(pid int) []int { return nil }
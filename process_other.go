@@ -0,0 +1,30 @@
+//go:build !unix && !windows
+
+package opencode
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcess(p *os.Process) error {
+	return p.Signal(os.Interrupt)
+}
+
+func killProcess(p *os.Process) error {
+	if err := p.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+func killPIDs(pids []int) {
+	for _, pid := range pids {
+		if p, err := os.FindProcess(pid); err == nil {
+			p.Kill()
+		}
+	}
+}
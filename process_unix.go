@@ -0,0 +1,40 @@
+//go:build unix
+
+package opencode
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Run opencode in its own process group so its bun and LSP children can be
+// signalled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+func terminateProcess(p *os.Process) error {
+	return signalGroup(p, syscall.SIGTERM)
+}
+
+func killProcess(p *os.Process) error {
+	return signalGroup(p, syscall.SIGKILL)
+}
+
+func signalGroup(p *os.Process, sig syscall.Signal) error {
+	if err := syscall.Kill(-p.Pid, sig); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
+
+func killPIDs(pids []int) {
+	for _, pid := range pids {
+		syscall.Kill(pid, syscall.SIGKILL)
+	}
+}
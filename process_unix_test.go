@@ -0,0 +1,554 @@
+//go:build unix
+
+package opencode
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/ai-shift/opencode/opencodeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("OPENCODE_FAKE_SERVER") != "" {
+		fakeServerMain()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServerMain stands in for "opencode serve" when the test binary is run
+// through fakeServer. With OPENCODE_FAKE_CRASHES=n it crashes shortly after
+// becoming ready on each of its first n launches, counted in
+// OPENCODE_FAKE_STATE.
+func fakeServerMain() {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	hostname := flags.String("hostname", "127.0.0.1", "")
+	port := flags.Int("port", 0, "")
+	flags.Parse(os.Args[2:])
+
+	if crashes, _ := strconv.Atoi(os.Getenv("OPENCODE_FAKE_CRASHES")); crashes > 0 {
+		state := os.Getenv("OPENCODE_FAKE_STATE")
+		data, _ := os.ReadFile(state)
+		launches := strings.Count(string(data), "\n")
+		os.WriteFile(state, append(data, '\n'), 0644)
+		if launches < crashes {
+			time.AfterFunc(time.Second, func() {
+				fmt.Fprintln(os.Stderr, "fake crash")
+				os.Exit(1)
+			})
+		}
+	}
+
+	http.HandleFunc("/global/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"healthy":true}`))
+	})
+	http.HandleFunc("/event", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"type\":\"server.connected\",\"properties\":{}}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	http.ListenAndServe(fmt.Sprintf("%s:%d", *hostname, *port), nil)
+}
+
+// fakeServer makes "opencode" run fakeServerMain, crashing on the first
+// crashes launches.
+func fakeServer(t *testing.T, crashes int) {
+	t.Helper()
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	t.Setenv("OPENCODE_FAKE_SERVER", "1")
+	t.Setenv("OPENCODE_FAKE_CRASHES", strconv.Itoa(crashes))
+	t.Setenv("OPENCODE_FAKE_STATE", filepath.Join(t.TempDir(), "launches"))
+	fakeOpencode(t, fmt.Sprintf("exec %q \"$@\"", exe))
+}
+
+func launchScript(t *testing.T, oc *OpenCode, script string) {
+	t.Helper()
+	oc.cmd = exec.Command("sh", "-c", script)
+	require.NoError(t, oc.launch())
+	// Give the shell time to install its traps
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestStopGraceful(t *testing.T) {
+	oc := New(Config{StopTimeout: 5 * time.Second})
+	launchScript(t, oc, `trap "exit 0" TERM; while :; do sleep 0.05; done`)
+
+	start := time.Now()
+	require.NoError(t, oc.Stop(context.Background()))
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Nil(t, oc.cmd)
+}
+
+func TestStopKillsAfterTimeout(t *testing.T) {
+	oc := New(Config{StopTimeout: 200 * time.Millisecond})
+	launchScript(t, oc, `trap "" TERM; while :; do sleep 0.05; done`)
+
+	start := time.Now()
+	require.NoError(t, oc.Stop(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	select {
+	case <-oc.exited:
+	default:
+		t.Fatal("process still running")
+	}
+}
+
+func TestStopKillsWhenContextDone(t *testing.T) {
+	oc := New(Config{StopTimeout: time.Minute})
+	launchScript(t, oc, `trap "" TERM; while :; do sleep 0.05; done`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, oc.Stop(ctx))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// fakeOpencode puts an "opencode" script running the given shell body first on
+// PATH.
+func fakeOpencode(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" + body + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "opencode"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRestart(t *testing.T) {
+	fakeOpencode(t, `trap "exit 0" TERM; while :; do sleep 0.05; done`)
+
+	restarted := 0
+	oc := New(Config{
+		ConfigFS:  fstest.MapFS{"config.json": {Data: []byte(`{}`)}},
+		OnRestart: func() { restarted++ },
+	})
+	require.NoError(t, oc.Start())
+	defer oc.Cleanup()
+	defer oc.Stop(context.Background())
+
+	addr, configDir, pid := oc.Addr(), oc.configDir, oc.cmd.Process.Pid
+	require.NoError(t, oc.Restart(context.Background()))
+	assert.Equal(t, addr, oc.Addr())
+	assert.Equal(t, configDir, oc.configDir)
+	assert.NotEqual(t, pid, oc.cmd.Process.Pid)
+	assert.Equal(t, 1, restarted)
+
+	oc.config.RestartOnNewPort = true
+	require.NoError(t, oc.Restart(context.Background()))
+	assert.NotEqual(t, addr, oc.Addr())
+	assert.Equal(t, 2, restarted)
+}
+
+func TestStartWithBinary(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	binary := filepath.Join(t.TempDir(), "opencode-1.2.3")
+	require.NoError(t, os.WriteFile(binary, []byte("#!/bin/sh\nexit 0\n"), 0755))
+
+	oc := New(Config{BinaryPath: "/missing"}, WithBinary(binary))
+	require.NoError(t, oc.Start())
+	assert.Equal(t, binary, oc.cmd.Path)
+	<-oc.Done()
+}
+
+func TestStartMinVersion(t *testing.T) {
+	fakeOpencode(t, `[ "$1" = "--version" ] && echo 0.9.1 && exit 0; exit 0`)
+
+	oc := New(Config{MinVersion: "0.10.0"})
+	err := oc.Start()
+	assert.ErrorIs(t, err, ErrVersionTooOld)
+	assert.ErrorContains(t, err, "0.9.1 is older than the required 0.10.0")
+	assert.Nil(t, oc.cmd)
+
+	oc = New(Config{MinVersion: "0.9.0"})
+	require.NoError(t, oc.Start())
+	<-oc.Done()
+}
+
+func TestStartEnv(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env")
+	fakeOpencode(t, `echo "$API_KEY $SHELL" > `+out)
+	t.Setenv("SHELL", "/bin/inherited")
+
+	oc := New(Config{
+		ConfigFS: fstest.MapFS{"config.json": {Data: []byte(`{"key":"${API_KEY}"}`)}},
+		Env:      map[string]string{"API_KEY": "secret", "SHELL": "/bin/override"},
+	})
+	require.NoError(t, oc.Start())
+	defer oc.Cleanup()
+	<-oc.Done()
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "secret /bin/override\n", string(data))
+	config, err := os.ReadFile(filepath.Join(oc.configDir, "config.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"key":"secret"}`, string(config))
+	_, set := os.LookupEnv("API_KEY")
+	assert.False(t, set)
+}
+
+func TestStartConfigAndDataDir(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "env")
+	fakeOpencode(t, `echo "$OPENCODE_CONFIG_DIR $XDG_DATA_HOME $(pwd)" > `+out)
+	configDir := filepath.Join(t.TempDir(), "config")
+	dataDir := filepath.Join(t.TempDir(), "data")
+	workDir := t.TempDir()
+
+	oc := New(Config{
+		ConfigFS:  fstest.MapFS{"agent/review.md": {Data: []byte("review")}},
+		ConfigDir: configDir,
+		DataDir:   dataDir,
+		CWD:       workDir,
+	})
+	require.NoError(t, oc.Start())
+	<-oc.Done()
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, configDir+" "+dataDir+" "+workDir+"\n", string(data))
+	assert.FileExists(t, filepath.Join(configDir, "agent", "review.md"))
+	assert.DirExists(t, dataDir)
+
+	// Cleanup only removes temporary config directories
+	require.NoError(t, oc.Cleanup())
+	assert.DirExists(t, configDir)
+}
+
+func TestStartTempConfigDir(t *testing.T) {
+	fakeOpencode(t, `exit 0`)
+	parent := t.TempDir()
+	configFS := fstest.MapFS{"config.json": {Data: []byte(`{}`)}}
+
+	oc := New(Config{ConfigFS: configFS, TempDir: parent})
+	require.NoError(t, oc.Start())
+	<-oc.Done()
+	assert.Equal(t, parent, filepath.Dir(oc.configDir))
+	assert.True(t, strings.HasPrefix(filepath.Base(oc.configDir), "opencode_"))
+	info, err := os.Stat(oc.configDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	require.NoError(t, oc.Cleanup())
+
+	oc = New(Config{ConfigFS: configFS, TempDir: parent, ConfigDirMode: 0750})
+	require.NoError(t, oc.Start())
+	defer oc.Cleanup()
+	<-oc.Done()
+	info, err = os.Stat(oc.configDir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+}
+
+func TestStartOpenCodeConfig(t *testing.T) {
+	fakeOpencode(t, `exit 0`)
+
+	oc := New(Config{
+		TempDir:        t.TempDir(),
+		ConfigFS:       fstest.MapFS{"agent/review.md": {Data: []byte("review")}},
+		OpenCodeConfig: &opencodeconfig.Config{Model: "anthropic/claude-sonnet-4-5"},
+	})
+	require.NoError(t, oc.Start())
+	defer oc.Cleanup()
+	<-oc.Done()
+	data, err := os.ReadFile(filepath.Join(oc.configDir, "config.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"model": "anthropic/claude-sonnet-4-5"`)
+	assert.FileExists(t, filepath.Join(oc.configDir, "agent", "review.md"))
+
+	parent := t.TempDir()
+	oc = New(Config{TempDir: parent, OpenCodeConfig: &opencodeconfig.Config{Model: "sonnet"}})
+	assert.ErrorContains(t, oc.Start(), "invalid opencode config")
+	entries, _ := os.ReadDir(parent)
+	assert.Empty(t, entries)
+
+	oc = New(Config{
+		TempDir:        parent,
+		ConfigFS:       fstest.MapFS{"config.json": {Data: []byte(`{}`)}},
+		OpenCodeConfig: &opencodeconfig.Config{},
+	})
+	assert.ErrorContains(t, oc.Start(), "conflicts with OpenCodeConfig")
+}
+
+func TestDoneReportsExit(t *testing.T) {
+	oc := New(Config{})
+	assert.Nil(t, oc.ExitState())
+
+	oc.cmd = exec.Command("sh", "-c", `echo "bad config" >&2; exit 3`)
+	require.NoError(t, oc.launch())
+
+	select {
+	case err := <-oc.Done():
+		var exitErr *ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, 3, exitErr.Code)
+		assert.Equal(t, "opencode exited with code 3: bad config", err.Error())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done did not fire")
+	}
+
+	state := oc.ExitState()
+	require.NotNil(t, state)
+	assert.Equal(t, 3, state.Code)
+	assert.Equal(t, "bad config\n", state.Stderr)
+}
+
+func TestWaitForReadyProcessExits(t *testing.T) {
+	oc := New(Config{Addr: "127.0.0.1:1"})
+	oc.cmd = exec.Command("sh", "-c", `echo "missing provider" >&2; exit 1`)
+	require.NoError(t, oc.launch())
+
+	start := time.Now()
+	err := oc.WaitForReady(context.Background(), 10*time.Second)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.ErrorIs(t, err, ErrNotReady)
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.Code)
+	assert.ErrorContains(t, err, "missing provider")
+}
+
+func TestWaitForReadyStopsPollingAfterExit(t *testing.T) {
+	var probes atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.Write([]byte(`{"healthy":true}`))
+	})
+	oc.cmd = exec.Command("sh", "-c", "exit 1")
+	require.NoError(t, oc.launch())
+
+	// Without a timeout the poller must still stop when WaitForReady returns
+	err := oc.WaitForReady(context.Background())
+	assert.ErrorIs(t, err, ErrNotReady)
+	time.Sleep(1200 * time.Millisecond)
+	assert.Zero(t, probes.Load())
+}
+
+func TestDoneCleanExit(t *testing.T) {
+	oc := New(Config{})
+	oc.cmd = exec.Command("true")
+	require.NoError(t, oc.launch())
+
+	select {
+	case err := <-oc.Done():
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done did not fire")
+	}
+	assert.Equal(t, 0, oc.ExitState().Code)
+}
+
+func TestLogs(t *testing.T) {
+	var stderr strings.Builder
+	oc := New(Config{Stderr: &stderr})
+	oc.cmd = exec.Command("sh", "-c", `echo one; echo two; echo oops >&2`)
+	oc.cmd.Stderr = oc.config.Stderr
+	require.NoError(t, oc.launch())
+	<-oc.Done()
+
+	// stdout and stderr are separate pipes, so only the order within each is fixed
+	assert.ElementsMatch(t, []string{"one", "two", "oops"}, oc.Logs(0))
+	assert.Len(t, oc.Logs(1), 1)
+	assert.Equal(t, "oops\n", stderr.String())
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := newTailBuffer(5)
+	b.Write([]byte("abc"))
+	b.Write([]byte("defg"))
+	assert.Equal(t, "cdefg", b.String())
+}
+
+type supervisorEvents struct {
+	mu     sync.Mutex
+	events []SupervisorEvent
+}
+
+func (s *supervisorEvents) add(event SupervisorEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *supervisorEvents) kinds() []SupervisorEventKind {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var kinds []SupervisorEventKind
+	for _, event := range s.events {
+		kinds = append(kinds, event.Kind)
+	}
+	return kinds
+}
+
+func TestAutoRestart(t *testing.T) {
+	fakeServer(t, 2)
+
+	var events supervisorEvents
+	var restarted atomic.Int32
+	oc := New(Config{
+		AutoRestart: &RestartPolicy{InitialBackoff: 10 * time.Millisecond, OnEvent: events.add},
+		OnRestart:   func() { restarted.Add(1) },
+	})
+	require.NoError(t, oc.Start())
+	defer oc.Stop(context.Background())
+	require.NoError(t, oc.WaitForReady(context.Background(), 5*time.Second))
+	addr := oc.Addr()
+
+	want := []SupervisorEventKind{SupervisorRestarting, SupervisorRestarted, SupervisorRestarting, SupervisorRestarted}
+	require.Eventually(t, func() bool {
+		return len(events.kinds()) == len(want)
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, want, events.kinds())
+	assert.Equal(t, addr, oc.Addr())
+
+	events.mu.Lock()
+	first := events.events[0]
+	events.mu.Unlock()
+	assert.Equal(t, 1, first.Attempt)
+	assert.Equal(t, 1, first.Exit.Code)
+	assert.Equal(t, "fake crash\n", first.Exit.Stderr)
+
+	// The third launch stays up and stopping it must not trigger a restart
+	require.NoError(t, oc.Stop(context.Background()))
+	time.Sleep(200 * time.Millisecond)
+	assert.Len(t, events.kinds(), len(want))
+	assert.EqualValues(t, 2, restarted.Load())
+}
+
+func TestAutoRestartGivesUp(t *testing.T) {
+	fakeServer(t, 100)
+
+	var events supervisorEvents
+	oc := New(Config{
+		AutoRestart: &RestartPolicy{MaxRestarts: 1, InitialBackoff: 10 * time.Millisecond, OnEvent: events.add},
+	})
+	require.NoError(t, oc.Start())
+	defer oc.Stop(context.Background())
+
+	want := []SupervisorEventKind{SupervisorRestarting, SupervisorRestarted, SupervisorGaveUp}
+	require.Eventually(t, func() bool {
+		return len(events.kinds()) == len(want)
+	}, 10*time.Second, 50*time.Millisecond)
+	assert.Equal(t, want, events.kinds())
+}
+
+func TestAutoRestartStopDuringBackoff(t *testing.T) {
+	fakeServer(t, 1)
+
+	var events supervisorEvents
+	oc := New(Config{
+		AutoRestart: &RestartPolicy{InitialBackoff: time.Hour, OnEvent: events.add},
+	})
+	require.NoError(t, oc.Start())
+	require.Eventually(t, func() bool {
+		return len(events.kinds()) == 1
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, oc.Stop(context.Background()))
+	// The supervisor must not stay asleep for the rest of the backoff
+	assert.Eventually(t, func() bool {
+		buf := make([]byte, 1<<20)
+		return !strings.Contains(string(buf[:runtime.Stack(buf, true)]), "(*OpenCode).supervise")
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(t, []SupervisorEventKind{SupervisorRestarting}, events.kinds())
+}
+
+func TestRestartPolicyBackoff(t *testing.T) {
+	p := &RestartPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+}
+
+func TestStartFixedPort(t *testing.T) {
+	fakeServer(t, 0)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	oc := New(Config{Hostname: "0.0.0.0", Port: port})
+	require.NoError(t, oc.Start())
+	defer oc.Stop(context.Background())
+	assert.Equal(t, fmt.Sprintf("127.0.0.1:%d", port), oc.Addr())
+	assert.Contains(t, oc.cmd.Args, "0.0.0.0")
+	require.NoError(t, oc.WaitForReady(context.Background(), 5*time.Second))
+}
+
+func TestClose(t *testing.T) {
+	fakeServer(t, 0)
+	oc := New(Config{TempDir: t.TempDir(), ConfigFS: fstest.MapFS{"config.json": {Data: []byte(`{}`)}}})
+	require.NoError(t, oc.Start())
+	require.NoError(t, oc.WaitForReady(context.Background(), 10*time.Second))
+	configDir := oc.configDir
+
+	events := oc.Subscribe(context.Background())
+	<-events
+	streamErr := make(chan error, 1)
+	connected := make(chan struct{})
+	go func() {
+		streamErr <- oc.StreamEvents(context.Background(), func(Event) { close(connected) })
+	}()
+	<-connected
+
+	require.NoError(t, oc.Close(context.Background()))
+	_, open := <-events
+	assert.False(t, open, "subscriptions must be closed")
+	assert.ErrorIs(t, <-streamErr, ErrClosed)
+	assert.Nil(t, oc.cmd)
+	assert.NoDirExists(t, configDir)
+
+	assert.ErrorIs(t, oc.StreamEvents(context.Background(), func(Event) {}), ErrClosed)
+	_, open = <-oc.Subscribe(context.Background())
+	assert.False(t, open)
+	require.NoError(t, oc.Close(context.Background()), "Close must be idempotent")
+}
+
+// TestConcurrentStartAndRequests is meant for go test -race: requests and
+// event streams read the address while Start sets it.
+func TestConcurrentStartAndRequests(t *testing.T) {
+	fakeServer(t, 0)
+	oc := New(Config{})
+	defer oc.Close(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, oc.Start())
+	}()
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+				oc.Addr()
+				oc.SendMessage(ctx, "ses_1", Text("hi"))
+				oc.StreamEvents(ctx, func(Event) {})
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, oc.WaitForReady(context.Background(), 10*time.Second))
+}
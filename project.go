@@ -0,0 +1,39 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+type Project struct {
+	ID       string `json:"id"`
+	Worktree string `json:"worktree"`
+	// VCS is "git" for git repositories, empty otherwise.
+	VCS  string      `json:"vcs,omitempty"`
+	Time ProjectTime `json:"time"`
+}
+
+type ProjectTime struct {
+	Created     int64 `json:"created"`
+	Initialized int64 `json:"initialized,omitempty"`
+}
+
+// ListProjects returns every project the server has opened sessions in.
+func (oc *OpenCode) ListProjects(ctx context.Context) ([]Project, error) {
+	var projects []Project
+	if err := oc.do(ctx, http.MethodGet, "/project", nil, &projects); err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+	return projects, nil
+}
+
+// CurrentProject returns the project of the request's directory, see
+// WithDirectory.
+func (oc *OpenCode) CurrentProject(ctx context.Context) (*Project, error) {
+	var project Project
+	if err := oc.do(ctx, http.MethodGet, "/project/current", nil, &project); err != nil {
+		return nil, fmt.Errorf("failed to get current project: %w", err)
+	}
+	return &project, nil
+}
@@ -0,0 +1,35 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListProjects(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/project":
+			w.Write([]byte(`[{"id":"a1b2","worktree":"/work/api","vcs":"git","time":{"created":1700000000000}},{"id":"global","worktree":"/","time":{"created":1600000000000}}]`))
+		case "/project/current":
+			assert.Equal(t, "/work/api", r.URL.Query().Get("directory"))
+			w.Write([]byte(`{"id":"a1b2","worktree":"/work/api","vcs":"git","time":{"created":1700000000000}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+
+	projects, err := oc.ListProjects(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Project{
+		{ID: "a1b2", Worktree: "/work/api", VCS: "git", Time: ProjectTime{Created: 1700000000000}},
+		{ID: "global", Worktree: "/", Time: ProjectTime{Created: 1600000000000}},
+	}, projects)
+
+	project, err := oc.CurrentProject(WithDirectory(context.Background(), "/work/api"))
+	require.NoError(t, err)
+	assert.Equal(t, projects[0], *project)
+}
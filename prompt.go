@@ -0,0 +1,109 @@
+package opencode
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type MessageOption func(*messageRequest) error
+
+type messageRequest struct {
+	MessageID string      `json:"messageID,omitempty"`
+	Agent     string      `json:"agent,omitempty"`
+	Model     *Model      `json:"model,omitempty"`
+	Parts     []partInput `json:"parts"`
+}
+
+type partInput struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Mime     string `json:"mime,omitempty"`
+	Filename string `json:"filename,omitempty"`
+	URL      string `json:"url,omitempty"`
+}
+
+func Text(text string) MessageOption {
+	return func(req *messageRequest) error {
+		req.Parts = append(req.Parts, partInput{Type: PartTypeText, Text: text})
+		return nil
+	}
+}
+
+func FileAttachment(path string) MessageOption {
+	return func(req *messageRequest) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read attachment %s: %w", path, err)
+		}
+		mimeType := mime.TypeByExtension(filepath.Ext(path))
+		if mimeType == "" {
+			mimeType = http.DetectContentType(data)
+		}
+		if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+			mimeType = mediaType
+		}
+		return FileData(filepath.Base(path), mimeType, data)(req)
+	}
+}
+
+func FileData(filename, mimeType string, data []byte) MessageOption {
+	return func(req *messageRequest) error {
+		req.Parts = append(req.Parts, partInput{
+			Type:     PartTypeFile,
+			Mime:     mimeType,
+			Filename: filename,
+			URL:      fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+		})
+		return nil
+	}
+}
+
+// WithAgent sends the message to the named agent, e.g. "plan" or a custom
+// subagent. ListAgents returns the available names.
+func WithAgent(name string) MessageOption {
+	return func(req *messageRequest) error {
+		req.Agent = name
+		return nil
+	}
+}
+
+// WithModel answers the message with the given model instead of the
+// session's or agent's default.
+func WithModel(model Model) MessageOption {
+	return func(req *messageRequest) error {
+		req.Model = &model
+		return nil
+	}
+}
+
+// WithMessageID sends the prompt as the user message with the given ID,
+// created with NewMessageID. Retrying SendMessage with the same ID does not
+// prompt the model again but returns the answer to the first attempt, so
+// store the ID with the job before the first attempt.
+func WithMessageID(id string) MessageOption {
+	return func(req *messageRequest) error {
+		if !strings.HasPrefix(id, "msg") {
+			return fmt.Errorf("message ID %q must start with msg, see NewMessageID", id)
+		}
+		req.MessageID = id
+		return nil
+	}
+}
+
+// NewMessageID returns a new message ID for WithMessageID. IDs sort by
+// creation time, like the ones the server assigns.
+func NewMessageID() string {
+	return newID("msg")
+}
+
+func filePartInput(file *FilePart) MessageOption {
+	return func(req *messageRequest) error {
+		req.Parts = append(req.Parts, partInput{Type: PartTypeFile, Mime: file.Mime, Filename: file.Filename, URL: file.URL})
+		return nil
+	}
+}
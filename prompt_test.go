@@ -0,0 +1,181 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendMessageWithAttachment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "build.out123")
+	require.NoError(t, os.WriteFile(path, []byte("boom"), 0644))
+
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/session/ses_1/message", r.URL.Path)
+		var req messageRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, []partInput{
+			{Type: "text", Text: "explain this"},
+			{Type: "file", Mime: "text/plain", Filename: "build.out123", URL: "data:text/plain;base64,Ym9vbQ=="},
+			{Type: "file", Mime: "image/png", Filename: "shot.png", URL: "data:image/png;base64,AQI="},
+		}, req.Parts)
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant"},"parts":[{"id":"prt_1","type":"text","text":"ok"}]}`))
+	})
+
+	message, err := oc.SendMessage(context.Background(), "ses_1",
+		Text("explain this"),
+		FileAttachment(path),
+		FileData("shot.png", "image/png", []byte{1, 2}),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "msg_2", message.Info.ID)
+}
+
+func TestSendMessageMissingAttachment(t *testing.T) {
+	oc := New(Config{})
+	_, err := oc.SendMessage(context.Background(), "ses_1", FileAttachment("/does/not/exist.png"))
+	assert.ErrorContains(t, err, "failed to read attachment")
+}
+
+func TestSendMessageWithoutParts(t *testing.T) {
+	oc := New(Config{})
+	_, err := oc.SendMessage(context.Background(), "ses_1")
+	assert.EqualError(t, err, "message has no parts")
+}
+
+func TestSendMessageQueueSends(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session/status" {
+			w.Write([]byte(`{}`))
+			return
+		}
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		if n > maxInFlight.Load() {
+			maxInFlight.Store(n)
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(`{"info":{"id":"msg","role":"assistant"},"parts":[]}`))
+	})
+	oc.config.QueueSends = true
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := oc.SendMessage(context.Background(), "ses_1", Text("hi"))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), maxInFlight.Load())
+	assert.Empty(t, oc.queues)
+}
+
+func TestSendMessageQueueWaitsForIdle(t *testing.T) {
+	var polls, posts atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/status":
+			// Busy with a prompt from another client for two polls
+			if polls.Add(1) <= 2 {
+				w.Write([]byte(`{"ses_1":{"type":"busy"},"ses_2":{"type":"busy"}}`))
+				return
+			}
+			w.Write([]byte(`{"ses_2":{"type":"busy"}}`))
+		case "/event":
+			sseHandler()(w, r)
+		default:
+			posts.Add(1)
+			assert.EqualValues(t, 3, polls.Load())
+			w.Write([]byte(`{"info":{"id":"msg","role":"assistant"},"parts":[]}`))
+		}
+	})
+	oc.config.QueueSends = true
+
+	_, err := oc.SendMessage(context.Background(), "ses_1", Text("hi"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, posts.Load())
+}
+
+func TestSendMessageQueueCancelled(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	})
+	oc.config.QueueSends = true
+	release, err := oc.acquireSession(context.Background(), "ses_1")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = oc.SendMessage(ctx, "ses_1", Text("hi"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	release()
+	assert.Empty(t, oc.queues)
+}
+
+func TestSendMessageIdempotent(t *testing.T) {
+	id := NewMessageID()
+	var posts atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/session/ses_1/message/"+id:
+			if posts.Load() == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"info":{"id":"` + id + `","role":"user"},"parts":[]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/session/ses_1/message":
+			w.Write([]byte(`[
+				{"info":{"id":"` + id + `","role":"user"},"parts":[]},
+				{"info":{"id":"msg_a1","role":"assistant","parentID":"` + id + `","finish":"tool-calls","time":{"created":1,"completed":2}},"parts":[]},
+				{"info":{"id":"msg_a2","role":"assistant","parentID":"` + id + `","finish":"stop","time":{"created":3,"completed":4}},"parts":[]}
+			]`))
+		case r.Method == http.MethodPost:
+			posts.Add(1)
+			var req messageRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, id, req.MessageID)
+			// The client gives up before the answer arrives
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`{"info":{"id":"msg_a2","role":"assistant"},"parts":[]}`))
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := oc.SendMessage(ctx, "ses_1", WithMessageID(id), Text("hi"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	message, err := oc.SendMessage(context.Background(), "ses_1", WithMessageID(id), Text("hi"))
+	require.NoError(t, err)
+	assert.Equal(t, "msg_a2", message.Info.ID)
+	assert.Equal(t, int32(1), posts.Load(), "a retry must not prompt the model again")
+
+	_, err = oc.SendMessage(context.Background(), "ses_1", WithMessageID("job-42"), Text("hi"))
+	assert.ErrorContains(t, err, "must start with msg")
+}
+
+func TestSendMessageWithModel(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"providerID": "openai", "modelID": "gpt-5"}, body["model"])
+		w.Write([]byte(`{"info":{"id":"msg_2","role":"assistant"},"parts":[]}`))
+	})
+
+	_, err := oc.SendMessage(context.Background(), "ses_1", WithModel(Model{ProviderID: "openai", ModelID: "gpt-5"}), Text("hi"))
+	require.NoError(t, err)
+}
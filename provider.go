@@ -0,0 +1,151 @@
+package opencode
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// providerKeyEnv maps provider IDs to the environment variable opencode reads
+// their API key from.
+var providerKeyEnv = map[string]string{
+	"anthropic":      "ANTHROPIC_API_KEY",
+	"openai":         "OPENAI_API_KEY",
+	"openrouter":     "OPENROUTER_API_KEY",
+	"google":         "GOOGLE_GENERATIVE_AI_API_KEY",
+	"groq":           "GROQ_API_KEY",
+	"mistral":        "MISTRAL_API_KEY",
+	"xai":            "XAI_API_KEY",
+	"deepseek":       "DEEPSEEK_API_KEY",
+	"togetherai":     "TOGETHER_AI_API_KEY",
+	"fireworks-ai":   "FIREWORKS_API_KEY",
+	"cerebras":       "CEREBRAS_API_KEY",
+	"opencode":       "OPENCODE_API_KEY",
+	"azure":          "AZURE_API_KEY",
+	"github-models":  "GITHUB_TOKEN",
+	"huggingface":    "HF_TOKEN",
+	"perplexity":     "PERPLEXITY_API_KEY",
+	"amazon-bedrock": "AWS_BEARER_TOKEN_BEDROCK",
+}
+
+// ProviderKeyEnv returns the environment variable holding the API key for a
+// provider. Unknown providers get the conventional NAME_API_KEY.
+func ProviderKeyEnv(providerID string) string {
+	if env, ok := providerKeyEnv[providerID]; ok {
+		return env
+	}
+	return strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(providerID)) + "_API_KEY"
+}
+
+type Provider struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Env lists the environment variables the provider reads its key from.
+	Env    []string                 `json:"env"`
+	Models map[string]ProviderModel `json:"models"`
+}
+
+type ProviderModel struct {
+	ID string `json:"id"`
+	// ProviderID is filled in by ListModels.
+	ProviderID  string     `json:"-"`
+	Name        string     `json:"name"`
+	ReleaseDate string     `json:"release_date,omitempty"`
+	Attachment  bool       `json:"attachment"`
+	Reasoning   bool       `json:"reasoning"`
+	Temperature bool       `json:"temperature"`
+	ToolCall    bool       `json:"tool_call"`
+	Cost        ModelCost  `json:"cost"`
+	Limit       ModelLimit `json:"limit"`
+}
+
+// ModelCost is in USD per million tokens.
+type ModelCost struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read,omitempty"`
+	CacheWrite float64 `json:"cache_write,omitempty"`
+}
+
+type ModelLimit struct {
+	Context int `json:"context"`
+	Output  int `json:"output"`
+}
+
+type Providers struct {
+	Providers []Provider `json:"providers"`
+	// Default maps provider IDs to their default model ID.
+	Default map[string]string `json:"default"`
+}
+
+// ListProviders returns the configured providers with their models.
+func (oc *OpenCode) ListProviders(ctx context.Context) (*Providers, error) {
+	var providers Providers
+	if err := oc.do(ctx, http.MethodGet, "/config/providers", nil, &providers); err != nil {
+		return nil, fmt.Errorf("failed to list providers: %w", err)
+	}
+	return &providers, nil
+}
+
+// ListModels returns the models of all configured providers sorted by
+// provider and model ID.
+func (oc *OpenCode) ListModels(ctx context.Context) ([]ProviderModel, error) {
+	providers, err := oc.ListProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var models []ProviderModel
+	for _, provider := range providers.Providers {
+		for _, id := range slices.Sorted(maps.Keys(provider.Models)) {
+			model := provider.Models[id]
+			model.ProviderID = provider.ID
+			models = append(models, model)
+		}
+	}
+	slices.SortStableFunc(models, func(a, b ProviderModel) int {
+		return cmp.Compare(a.ProviderID, b.ProviderID)
+	})
+	return models, nil
+}
+
+// Model returns the model as a Model for SendMessage and SummarizeSession.
+func (m ProviderModel) Model() Model {
+	return Model{ProviderID: m.ProviderID, ModelID: m.ID}
+}
+
+// Auth is a provider credential as stored in opencode's auth.json. Use
+// APIKeyAuth or OAuthAuth to build one.
+type Auth struct {
+	// Type is "api", "oauth" or "wellknown".
+	Type    string `json:"type"`
+	Key     string `json:"key,omitempty"`
+	Access  string `json:"access,omitempty"`
+	Refresh string `json:"refresh,omitempty"`
+	// Expires is the access token's expiry in Unix milliseconds.
+	Expires int64  `json:"expires,omitempty"`
+	Token   string `json:"token,omitempty"`
+}
+
+func APIKeyAuth(key string) Auth {
+	return Auth{Type: "api", Key: key}
+}
+
+func OAuthAuth(access, refresh string, expires time.Time) Auth {
+	return Auth{Type: "oauth", Access: access, Refresh: refresh, Expires: expires.UnixMilli()}
+}
+
+// SetAuth stores credentials for a provider on the running server, replacing
+// any existing ones. New requests use them without a restart.
+func (oc *OpenCode) SetAuth(ctx context.Context, providerID string, auth Auth) error {
+	if err := oc.do(ctx, http.MethodPut, "/auth/"+url.PathEscape(providerID), auth, nil); err != nil {
+		return fmt.Errorf("failed to set auth for %s: %w", providerID, err)
+	}
+	oc.log.Debug("Set provider auth", "provider", providerID, "type", auth.Type)
+	return nil
+}
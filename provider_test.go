@@ -0,0 +1,81 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderKeyEnv(t *testing.T) {
+	assert.Equal(t, "ANTHROPIC_API_KEY", ProviderKeyEnv("anthropic"))
+	assert.Equal(t, "GOOGLE_GENERATIVE_AI_API_KEY", ProviderKeyEnv("google"))
+	assert.Equal(t, "MY_PROVIDER_API_KEY", ProviderKeyEnv("my-provider"))
+}
+
+func TestEnvironProviderKeys(t *testing.T) {
+	oc := New(Config{
+		ProviderKeys: map[string]string{"anthropic": "sk-ant", "openrouter": "sk-or"},
+		Env:          map[string]string{"OPENROUTER_API_KEY": "from-env"},
+	})
+	env := oc.environ()
+	assert.Contains(t, env, "ANTHROPIC_API_KEY=sk-ant")
+	// Env entries come last so they win over provider keys
+	assert.Equal(t, "OPENROUTER_API_KEY=from-env", env[len(env)-1])
+	assert.Equal(t, "sk-ant", oc.getenv("ANTHROPIC_API_KEY"))
+	assert.Equal(t, "from-env", oc.getenv("OPENROUTER_API_KEY"))
+}
+
+func TestListProviders(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/config/providers", r.URL.Path)
+		w.Write([]byte(`{"providers":[
+			{"id":"openai","name":"OpenAI","env":["OPENAI_API_KEY"],"models":{
+				"gpt-5":{"id":"gpt-5","name":"GPT-5","reasoning":true,"tool_call":true,"cost":{"input":1.25,"output":10,"cache_read":0.125},"limit":{"context":400000,"output":128000}}}},
+			{"id":"anthropic","name":"Anthropic","env":["ANTHROPIC_API_KEY"],"models":{
+				"claude-sonnet-4-5":{"id":"claude-sonnet-4-5","name":"Claude Sonnet 4.5","cost":{"input":3,"output":15},"limit":{"context":200000,"output":64000}},
+				"claude-haiku-4-5":{"id":"claude-haiku-4-5","name":"Claude Haiku 4.5","cost":{"input":1,"output":5},"limit":{"context":200000,"output":64000}}}}
+		],"default":{"openai":"gpt-5","anthropic":"claude-sonnet-4-5"}}`))
+	})
+
+	providers, err := oc.ListProviders(context.Background())
+	require.NoError(t, err)
+	require.Len(t, providers.Providers, 2)
+	assert.Equal(t, "claude-sonnet-4-5", providers.Default["anthropic"])
+	gpt := providers.Providers[0].Models["gpt-5"]
+	assert.Equal(t, ModelCost{Input: 1.25, Output: 10, CacheRead: 0.125}, gpt.Cost)
+	assert.Equal(t, ModelLimit{Context: 400000, Output: 128000}, gpt.Limit)
+	assert.True(t, gpt.Reasoning)
+
+	models, err := oc.ListModels(context.Background())
+	require.NoError(t, err)
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ProviderID+"/"+m.ID)
+	}
+	assert.Equal(t, []string{"anthropic/claude-haiku-4-5", "anthropic/claude-sonnet-4-5", "openai/gpt-5"}, ids)
+	assert.Equal(t, Model{ProviderID: "openai", ModelID: "gpt-5"}, models[2].Model())
+}
+
+func TestSetAuth(t *testing.T) {
+	var auths []map[string]any
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/auth/anthropic", r.URL.Path)
+		var auth map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&auth))
+		auths = append(auths, auth)
+		w.Write([]byte("true"))
+	})
+
+	require.NoError(t, oc.SetAuth(context.Background(), "anthropic", APIKeyAuth("sk-ant")))
+	require.NoError(t, oc.SetAuth(context.Background(), "anthropic", OAuthAuth("at", "rt", time.UnixMilli(1700000000000))))
+	assert.Equal(t, []map[string]any{
+		{"type": "api", "key": "sk-ant"},
+		{"type": "oauth", "access": "at", "refresh": "rt", "expires": float64(1700000000000)},
+	}, auths)
+}
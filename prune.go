@@ -0,0 +1,106 @@
+package opencode
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// PrunePolicy selects the sessions PruneSessions deletes. Only top-level
+// sessions are considered; the server deletes sub-sessions along with their
+// parent.
+type PrunePolicy struct {
+	// MaxAge deletes sessions not updated for longer than this. Zero keeps
+	// sessions of any age.
+	MaxAge time.Duration
+	// MaxCount keeps only the most recently updated sessions. Zero keeps any
+	// number.
+	MaxCount int
+	// KeepShared never deletes shared sessions and does not count them
+	// towards MaxCount.
+	KeepShared bool
+	// Interval between runs of AutoPruneSessions. Defaults to an hour.
+	Interval time.Duration
+}
+
+// PruneSessions deletes the sessions selected by policy, along with their
+// messages and sub-sessions, and returns them. It keeps going when a
+// deletion fails and returns the deleted sessions with the joined errors.
+func (oc *OpenCode) PruneSessions(ctx context.Context, policy PrunePolicy) ([]Session, error) {
+	sessions, err := oc.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []Session
+	var errs []error
+	for _, session := range prunable(sessions, policy, time.Now()) {
+		if err := oc.DeleteSession(ctx, session.ID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			errs = append(errs, err)
+			continue
+		}
+		pruned = append(pruned, session)
+	}
+	if len(pruned) > 0 {
+		oc.log.Info("Pruned sessions", "count", len(pruned), "kept", len(sessions)-len(pruned))
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// prunable returns the sessions policy deletes, oldest first.
+func prunable(sessions []Session, policy PrunePolicy, now time.Time) []Session {
+	var candidates []Session
+	for _, session := range sessions {
+		if session.ParentID != "" || (policy.KeepShared && session.Share != nil) {
+			continue
+		}
+		candidates = append(candidates, session)
+	}
+	slices.SortStableFunc(candidates, func(a, b Session) int { return cmp.Compare(b.Time.Updated, a.Time.Updated) })
+
+	var pruned []Session
+	for i, session := range candidates {
+		tooOld := policy.MaxAge > 0 && now.Sub(time.UnixMilli(session.Time.Updated)) > policy.MaxAge
+		tooMany := policy.MaxCount > 0 && i >= policy.MaxCount
+		if tooOld || tooMany {
+			pruned = append(pruned, session)
+		}
+	}
+	slices.Reverse(pruned)
+	return pruned
+}
+
+// AutoPruneSessions runs PruneSessions every policy.Interval in the
+// background until ctx is done or Close is called. Failures are logged and
+// retried on the next run.
+func (oc *OpenCode) AutoPruneSessions(ctx context.Context, policy PrunePolicy) {
+	done, ok := oc.trackStream()
+	if !ok {
+		return
+	}
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(oc.closeCtx, cancel)
+	go func() {
+		defer done()
+		defer cancel()
+		defer stop()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if _, err := oc.PruneSessions(ctx, policy); err != nil && ctx.Err() == nil {
+				oc.log.Warn("Failed to prune sessions", "err", err)
+			}
+		}
+	}()
+}
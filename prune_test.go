@@ -0,0 +1,68 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrunable(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) SessionTime { return SessionTime{Updated: now.Add(-d).UnixMilli()} }
+	sessions := []Session{
+		{ID: "ses_new", Time: ago(time.Minute)},
+		{ID: "ses_old", Time: ago(48 * time.Hour)},
+		{ID: "ses_child", ParentID: "ses_old", Time: ago(72 * time.Hour)},
+		{ID: "ses_shared", Time: ago(96 * time.Hour), Share: &SessionShare{URL: "https://opncd.ai/s/1"}},
+		{ID: "ses_mid", Time: ago(time.Hour)},
+	}
+	ids := func(sessions []Session) []string {
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"ses_shared", "ses_old"}, ids(prunable(sessions, PrunePolicy{MaxAge: 24 * time.Hour}, now)))
+	assert.Equal(t, []string{"ses_old"}, ids(prunable(sessions, PrunePolicy{MaxAge: 24 * time.Hour, KeepShared: true}, now)))
+	assert.Equal(t, []string{"ses_shared", "ses_old"}, ids(prunable(sessions, PrunePolicy{MaxCount: 2}, now)))
+	assert.Equal(t, []string{"ses_old", "ses_mid"}, ids(prunable(sessions, PrunePolicy{MaxCount: 1, KeepShared: true}, now)))
+	assert.Empty(t, prunable(sessions, PrunePolicy{}, now))
+}
+
+func TestPruneSessions(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour).UnixMilli()
+	var mu sync.Mutex
+	var deleted []string
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `[{"id":"ses_1","time":{"updated":%d}},{"id":"ses_2","time":{"updated":%d}},{"id":"ses_3","time":{"updated":%d}}]`, old, old, time.Now().UnixMilli())
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/session/")
+		mu.Lock()
+		deleted = append(deleted, id)
+		mu.Unlock()
+		if id == "ses_2" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("true"))
+	})
+
+	pruned, err := oc.PruneSessions(context.Background(), PrunePolicy{MaxAge: 24 * time.Hour})
+	assert.Error(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, "ses_1", pruned[0].ID)
+	slices.Sort(deleted)
+	assert.Equal(t, []string{"ses_1", "ses_2"}, deleted)
+}
@@ -0,0 +1,56 @@
+package opencode
+
+import (
+	"context"
+	"strings"
+)
+
+func (m *MessageWithParts) Reasoning() string {
+	var sb strings.Builder
+	for _, part := range m.Parts {
+		if reasoning, ok := part.(*ReasoningPart); ok {
+			sb.WriteString(reasoning.Text)
+		}
+	}
+	return sb.String()
+}
+
+// ReasoningDeltas streams the reasoning text of a message as it is generated.
+// The channel is closed once the message completes or ctx is done.
+func (oc *OpenCode) ReasoningDeltas(ctx context.Context, messageID string) <-chan string {
+	ctx, cancel := context.WithCancel(ctx)
+	events := oc.SubscribeFiltered(ctx, EventFilter{Types: []string{EventMessagePartUpdated, EventMessageUpdated}})
+	out := make(chan string, cap(events))
+	go func() {
+		defer close(out)
+		defer cancel()
+		written := map[string]int{}
+		for event := range events {
+			switch e := event.(type) {
+			case *MessageUpdatedEvent:
+				if e.Info.ID == messageID && e.Info.Time.Completed > 0 {
+					return
+				}
+			case *MessagePartUpdatedEvent:
+				part, ok := e.Part.(*ReasoningPart)
+				if !ok || part.MessageID != messageID {
+					continue
+				}
+				delta := e.Delta
+				if delta == "" && len(part.Text) > written[part.ID] {
+					delta = part.Text[written[part.ID]:]
+				}
+				if delta == "" {
+					continue
+				}
+				written[part.ID] += len(delta)
+				select {
+				case out <- delta:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,34 @@
+package opencode
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReasoningDeltas(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	deltas := oc.ReasoningDeltas(context.Background(), "msg_2")
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_1","messageID":"msg_2","type":"reasoning","text":"Let"},"delta":"Let"}}`
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_2","messageID":"msg_2","type":"text","text":"answer"},"delta":"answer"}}`
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_3","messageID":"msg_3","type":"reasoning","text":"other"},"delta":"other"}}`
+	events <- `{"type":"message.part.updated","properties":{"part":{"id":"prt_1","messageID":"msg_2","type":"reasoning","text":"Let me think"}}}`
+	events <- `{"type":"message.updated","properties":{"info":{"id":"msg_2","role":"assistant","time":{"created":1,"completed":2}}}}`
+
+	var got []string
+	for delta := range deltas {
+		got = append(got, delta)
+	}
+	assert.Equal(t, []string{"Let", " me think"}, got)
+}
+
+func TestMessageReasoning(t *testing.T) {
+	message := &MessageWithParts{Parts: []Part{
+		&ReasoningPart{Text: "hmm"},
+		&TextPart{Text: "answer"},
+	}}
+	assert.Equal(t, "hmm", message.Reasoning())
+	assert.Equal(t, "answer", message.Text())
+}
@@ -0,0 +1,134 @@
+package opencode
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveLogKeys are attribute keys whose values are never logged unless
+// Config.LogVerbose is set: credentials and user or agent content.
+var sensitiveLogKeys = []string{
+	"apikey", "key", "token", "access", "refresh", "authorization", "password", "secret",
+	"text", "prompt", "content", "arguments",
+}
+
+// secretPattern matches API keys and tokens inside otherwise harmless
+// values, such as command line arguments or error messages.
+var secretPattern = regexp.MustCompile(`\b(sk-[A-Za-z0-9_-]{8,}|gh[pousr]_[A-Za-z0-9]{20,}|AIza[0-9A-Za-z_-]{30,}|Bearer\s+\S+)|\b([A-Z0-9_]*(?:KEY|TOKEN|SECRET|PASSWORD)=)\S+`)
+
+// secretName matches Config.Env and Config.Headers names whose values are
+// credentials, such as ANTHROPIC_API_KEY, Authorization or X-Api-Key.
+var secretName = regexp.MustCompile(`(?i)key|token|secret|password|auth|credential|cookie`)
+
+// minSecretLen keeps short Env and header values, which would mask unrelated
+// text, out of the literal secrets.
+const minSecretLen = 8
+
+func redactString(s string) string {
+	return secretPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if name, _, ok := strings.Cut(match, "="); ok && !strings.HasPrefix(match, "Bearer") {
+			return name + "=" + redacted
+		}
+		return redacted
+	})
+}
+
+// redactHandler removes secrets and content from log records before passing
+// them on. secrets replaces the credentials from Config literally, in case
+// they do not look like keys: provider keys, the bearer token, and the Env
+// and header values whose name marks them as credentials.
+type redactHandler struct {
+	next    slog.Handler
+	secrets *strings.Replacer
+}
+
+func newLogger(cfg Config) *slog.Logger {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if cfg.LogVerbose {
+		return logger
+	}
+	values := append(slices.Collect(maps.Values(cfg.ProviderKeys)), cfg.BearerToken)
+	for _, named := range []map[string]string{cfg.Env, cfg.Headers} {
+		for name, value := range named {
+			if secretName.MatchString(name) && len(value) >= minSecretLen {
+				values = append(values, value)
+			}
+		}
+	}
+	var secrets []string
+	for _, secret := range values {
+		if secret != "" {
+			secrets = append(secrets, secret, redacted)
+		}
+	}
+	return slog.New(&redactHandler{next: logger.Handler(), secrets: strings.NewReplacer(secrets...)})
+}
+
+func (h *redactHandler) redact(s string) string {
+	return redactString(h.secrets.Replace(s))
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, record slog.Record) error {
+	out := slog.NewRecord(record.Time, record.Level, h.redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		out.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for i, attr := range attrs {
+		attrs[i] = h.redactAttr(attr)
+	}
+	return &redactHandler{next: h.next.WithAttrs(attrs), secrets: h.secrets}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), secrets: h.secrets}
+}
+
+func (h *redactHandler) redactAttr(attr slog.Attr) slog.Attr {
+	for _, key := range sensitiveLogKeys {
+		if strings.EqualFold(attr.Key, key) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, h.redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		attrs := make([]any, len(group))
+		for i, a := range group {
+			attrs[i] = h.redactAttr(a)
+		}
+		return slog.Group(attr.Key, attrs...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case []string:
+			out := make([]string, len(v))
+			for i, s := range v {
+				out[i] = h.redact(s)
+			}
+			return slog.Any(attr.Key, out)
+		case error:
+			return slog.String(attr.Key, h.redact(v.Error()))
+		}
+	}
+	return attr
+}
@@ -0,0 +1,59 @@
+package opencode
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactString(t *testing.T) {
+	assert.Equal(t, "key [REDACTED] here", redactString("key sk-ant-api03-abcdefgh here"))
+	assert.Equal(t, "Authorization: [REDACTED]", redactString("Authorization: Bearer abc.def"))
+	assert.Equal(t, "ANTHROPIC_API_KEY=[REDACTED] opencode", redactString("ANTHROPIC_API_KEY=secret opencode"))
+	assert.Equal(t, "session ses_1 message msg_2", redactString("session ses_1 message msg_2"))
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	oc := New(Config{Logger: logger, ProviderKeys: map[string]string{"local": "plainkey42"}})
+	oc.log.With("token", "abc").Info("Sending", "text", "my secret plan", "args", []string{"--key", "sk-proj-12345678"}, "err", errors.New("bad key sk-proj-12345678"), "session", "ses_1")
+	oc.log.Info("Calling provider with plainkey42")
+	out := buf.String()
+	assert.NotContains(t, out, "plainkey42")
+	assert.NotContains(t, out, "abc")
+	assert.NotContains(t, out, "secret plan")
+	assert.NotContains(t, out, "sk-proj")
+	assert.Contains(t, out, "session=ses_1")
+
+	buf.Reset()
+	oc = New(Config{
+		Logger: logger,
+		Env:    map[string]string{"ANTHROPIC_API_KEY": "envkey1234", "HOME": "/home/agent", "GITHUB_TOKEN": "x"},
+		Headers: map[string]string{
+			"Proxy-Authorization": "Basic cHJveHk6cGFzcw==",
+			"Cookie":              "session=cookie1234",
+			"X-Tenant":            "production",
+			"Accept":              "application/json",
+		},
+	})
+	oc.log.Info("Starting opencode", "env", []string{"ANTHROPIC_API_KEY=envkey1234", "OTHER=envkey1234"}, "home", "/home/agent")
+	oc.log.Info("Proxy rejected Basic cHJveHk6cGFzcw== with session=cookie1234")
+	oc.log.Info("Tenant production accepts application/json", "index", "x")
+	out = buf.String()
+	assert.NotContains(t, out, "envkey1234")
+	assert.NotContains(t, out, "cHJveHk6cGFzcw")
+	assert.NotContains(t, out, "cookie1234")
+	assert.Contains(t, out, "/home/agent")
+	assert.Contains(t, out, "Tenant production accepts application/json")
+	assert.Contains(t, out, "index=x")
+
+	buf.Reset()
+	oc = New(Config{Logger: logger, LogVerbose: true})
+	oc.log.Info("Sending", "text", "my secret plan")
+	assert.Contains(t, buf.String(), "secret plan")
+}
@@ -0,0 +1,306 @@
+package opencode
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type Session struct {
+	ID        string         `json:"id"`
+	ProjectID string         `json:"projectID"`
+	Directory string         `json:"directory"`
+	ParentID  string         `json:"parentID,omitempty"`
+	Title     string         `json:"title"`
+	Version   string         `json:"version"`
+	Time      SessionTime    `json:"time"`
+	Share     *SessionShare  `json:"share,omitempty"`
+	Revert    *SessionRevert `json:"revert,omitempty"`
+}
+
+type SessionTime struct {
+	Created    int64 `json:"created"`
+	Updated    int64 `json:"updated"`
+	Compacting int64 `json:"compacting,omitempty"`
+}
+
+type SessionShare struct {
+	URL string `json:"url"`
+}
+
+type SessionRevert struct {
+	MessageID string `json:"messageID"`
+	PartID    string `json:"partID,omitempty"`
+	Snapshot  string `json:"snapshot,omitempty"`
+	Diff      string `json:"diff,omitempty"`
+}
+
+type SessionCreate struct {
+	ParentID string `json:"parentID,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+type SessionSort int
+
+const (
+	// SortByUpdated lists the most recently active sessions first.
+	SortByUpdated SessionSort = iota
+	// SortByCreated lists the newest sessions first.
+	SortByCreated
+)
+
+// SessionQuery filters, sorts and pages ListSessions. The server is asked to
+// filter where it can, and everything is applied again to the response, so
+// servers that ignore the parameters give the same result.
+type SessionQuery struct {
+	// Title keeps sessions whose title contains it, ignoring case.
+	Title     string
+	ProjectID string
+	// ParentID keeps the sub-sessions of a session.
+	ParentID string
+	// Roots keeps only top-level sessions.
+	Roots  bool
+	SortBy SessionSort
+	// Offset skips sessions after sorting, and Limit caps how many are
+	// returned if positive.
+	Offset int
+	Limit  int
+}
+
+func (q SessionQuery) path() string {
+	params := url.Values{}
+	if q.Title != "" {
+		params.Set("search", q.Title)
+	}
+	if q.Roots {
+		params.Set("roots", "true")
+	}
+	if len(params) == 0 {
+		return "/session"
+	}
+	return "/session?" + params.Encode()
+}
+
+func (q SessionQuery) apply(sessions []Session) []Session {
+	sessions = slices.DeleteFunc(sessions, func(s Session) bool {
+		return (q.Title != "" && !strings.Contains(strings.ToLower(s.Title), strings.ToLower(q.Title))) ||
+			(q.ProjectID != "" && s.ProjectID != q.ProjectID) ||
+			(q.ParentID != "" && s.ParentID != q.ParentID) ||
+			(q.Roots && s.ParentID != "")
+	})
+	slices.SortStableFunc(sessions, func(a, b Session) int {
+		if q.SortBy == SortByCreated {
+			return cmp.Or(cmp.Compare(b.Time.Created, a.Time.Created), cmp.Compare(b.ID, a.ID))
+		}
+		return cmp.Or(cmp.Compare(b.Time.Updated, a.Time.Updated), cmp.Compare(b.ID, a.ID))
+	})
+	sessions = sessions[min(max(q.Offset, 0), len(sessions)):]
+	if q.Limit > 0 && len(sessions) > q.Limit {
+		sessions = sessions[:q.Limit]
+	}
+	return sessions
+}
+
+// ListSessions lists the sessions of the project, in the server's order
+// unless a query is given.
+func (oc *OpenCode) ListSessions(ctx context.Context, maybeQuery ...SessionQuery) ([]Session, error) {
+	var query *SessionQuery
+	path := "/session"
+	if len(maybeQuery) > 0 {
+		query = &maybeQuery[0]
+		path = query.path()
+	}
+	var sessions []Session
+	if err := oc.do(ctx, http.MethodGet, path, nil, &sessions); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if query != nil {
+		sessions = query.apply(sessions)
+	}
+	return sessions, nil
+}
+
+func (oc *OpenCode) CreateSession(ctx context.Context, create SessionCreate) (*Session, error) {
+	var session Session
+	if err := oc.do(ctx, http.MethodPost, "/session", create, &session); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	oc.log.Debug("Created session", "session", session.ID, "parent", session.ParentID)
+	return &session, nil
+}
+
+func (r *SessionRevert) Files() ([]FileDiff, error) {
+	return ParseDiff(r.Diff)
+}
+
+func (oc *OpenCode) GetSession(ctx context.Context, sessionID string) (*Session, error) {
+	var session Session
+	if err := oc.do(ctx, http.MethodGet, "/session/"+url.PathEscape(sessionID), nil, &session); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	return &session, nil
+}
+
+func (oc *OpenCode) ListSessionChildren(ctx context.Context, sessionID string) ([]Session, error) {
+	var sessions []Session
+	path := fmt.Sprintf("/session/%s/children", url.PathEscape(sessionID))
+	if err := oc.do(ctx, http.MethodGet, path, nil, &sessions); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	return sessions, nil
+}
+
+type SessionUpdate struct {
+	Title string `json:"title,omitempty"`
+}
+
+func (oc *OpenCode) UpdateSession(ctx context.Context, sessionID string, update SessionUpdate) (*Session, error) {
+	var session Session
+	if err := oc.do(ctx, http.MethodPatch, "/session/"+url.PathEscape(sessionID), update, &session); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	return &session, nil
+}
+
+func (oc *OpenCode) DeleteSession(ctx context.Context, sessionID string) error {
+	if err := oc.do(ctx, http.MethodDelete, "/session/"+url.PathEscape(sessionID), nil, nil); err != nil {
+		return sessionError(sessionID, err)
+	}
+	return nil
+}
+
+func (oc *OpenCode) RevertMessage(ctx context.Context, sessionID, messageID string) (*Session, error) {
+	var session Session
+	path := fmt.Sprintf("/session/%s/revert", url.PathEscape(sessionID))
+	body := map[string]string{"messageID": messageID}
+	if err := oc.do(ctx, http.MethodPost, path, body, &session); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	oc.log.Debug("Reverted session", "session", sessionID, "message", messageID)
+	return &session, nil
+}
+
+func (oc *OpenCode) UnrevertSession(ctx context.Context, sessionID string) (*Session, error) {
+	var session Session
+	path := fmt.Sprintf("/session/%s/unrevert", url.PathEscape(sessionID))
+	if err := oc.do(ctx, http.MethodPost, path, nil, &session); err != nil {
+		return nil, sessionError(sessionID, err)
+	}
+	oc.log.Debug("Unreverted session", "session", sessionID)
+	return &session, nil
+}
+
+type Model struct {
+	ProviderID string `json:"providerID"`
+	ModelID    string `json:"modelID"`
+}
+
+func (oc *OpenCode) SummarizeSession(ctx context.Context, sessionID string, maybeModel ...Model) error {
+	var model Model
+	if len(maybeModel) > 0 {
+		model = maybeModel[0]
+	} else {
+		last, err := oc.lastAssistantModel(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		model = last
+	}
+
+	oc.log.Debug("Summarizing session", "session", sessionID, "provider", model.ProviderID, "model", model.ModelID)
+	path := fmt.Sprintf("/session/%s/summarize", url.PathEscape(sessionID))
+	if err := oc.doPrompt(ctx, http.MethodPost, path, model, nil); err != nil {
+		return sessionError(sessionID, err)
+	}
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		session, err := oc.GetSession(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.Time.Compacting == 0 {
+			oc.log.Debug("Session summarized", "session", sessionID)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// InitSession asks the agent to analyze the project and write AGENTS.md. It
+// uses maybeModel, or the session's last model, or the configured default.
+func (oc *OpenCode) InitSession(ctx context.Context, sessionID string, maybeModel ...Model) error {
+	var model Model
+	if len(maybeModel) > 0 {
+		model = maybeModel[0]
+	} else {
+		last, err := oc.lastAssistantModel(ctx, sessionID)
+		if err != nil {
+			last, err = oc.defaultModel(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		model = last
+	}
+
+	oc.log.Debug("Initializing session", "session", sessionID, "provider", model.ProviderID, "model", model.ModelID)
+	body := struct {
+		MessageID string `json:"messageID"`
+		Model
+	}{MessageID: newID("msg"), Model: model}
+	path := fmt.Sprintf("/session/%s/init", url.PathEscape(sessionID))
+	if err := oc.doPrompt(ctx, http.MethodPost, path, body, nil); err != nil {
+		return sessionError(sessionID, err)
+	}
+	return nil
+}
+
+// defaultModel returns the model set in the server's config.
+func (oc *OpenCode) defaultModel(ctx context.Context) (Model, error) {
+	var config struct {
+		Model string `json:"model"`
+	}
+	if err := oc.do(ctx, http.MethodGet, "/config", nil, &config); err != nil {
+		return Model{}, fmt.Errorf("failed to get config: %w", err)
+	}
+	providerID, modelID, ok := strings.Cut(config.Model, "/")
+	if !ok {
+		return Model{}, errors.New("no model given and no default model configured")
+	}
+	return Model{ProviderID: providerID, ModelID: modelID}, nil
+}
+
+func (oc *OpenCode) lastAssistantModel(ctx context.Context, sessionID string) (Model, error) {
+	messages, err := oc.ListMessages(ctx, sessionID)
+	if err != nil {
+		return Model{}, err
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		info := messages[i].Info
+		if info.Role == RoleAssistant && info.ProviderID != "" && info.ModelID != "" {
+			return Model{ProviderID: info.ProviderID, ModelID: info.ModelID}, nil
+		}
+	}
+	return Model{}, fmt.Errorf("session %s has no assistant messages to infer the model from", sessionID)
+}
+
+func sessionError(sessionID string, err error) error {
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: %s: %w", ErrSessionNotFound, sessionID, err)
+	}
+	return fmt.Errorf("session %s: %w", sessionID, err)
+}
@@ -0,0 +1,234 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateSessionWithParent(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/session", r.URL.Path)
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"parentID": "ses_1"}, body)
+		w.Write([]byte(`{"id":"ses_2","parentID":"ses_1"}`))
+	})
+
+	session, err := oc.CreateSession(context.Background(), SessionCreate{ParentID: "ses_1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ses_2", session.ID)
+	assert.Equal(t, "ses_1", session.ParentID)
+}
+
+func TestListSessionsQuery(t *testing.T) {
+	var query atomic.Value
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		query.Store(r.URL.RawQuery)
+		// The server ignores the parameters.
+		w.Write([]byte(`[
+			{"id":"ses_1","projectID":"p1","title":"Fix login","time":{"created":1,"updated":5}},
+			{"id":"ses_2","projectID":"p1","title":"Refactor","time":{"created":2,"updated":2}},
+			{"id":"ses_3","projectID":"p1","title":"fix tests","time":{"created":3,"updated":3}},
+			{"id":"ses_4","projectID":"p1","parentID":"ses_3","title":"Fix subtask","time":{"created":4,"updated":4}},
+			{"id":"ses_5","projectID":"p2","title":"Fix other project","time":{"created":5,"updated":6}}
+		]`))
+	})
+	ids := func(sessions []Session) []string {
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+	ctx := context.Background()
+
+	sessions, err := oc.ListSessions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ses_1", "ses_2", "ses_3", "ses_4", "ses_5"}, ids(sessions))
+	assert.Empty(t, query.Load())
+
+	sessions, err = oc.ListSessions(ctx, SessionQuery{Title: "FIX", ProjectID: "p1", Roots: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ses_1", "ses_3"}, ids(sessions))
+	assert.Equal(t, "roots=true&search=FIX", query.Load())
+
+	sessions, err = oc.ListSessions(ctx, SessionQuery{SortBy: SortByCreated, Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ses_4", "ses_3"}, ids(sessions))
+
+	sessions, err = oc.ListSessions(ctx, SessionQuery{ParentID: "ses_3"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ses_4"}, ids(sessions))
+
+	sessions, err = oc.ListSessions(ctx, SessionQuery{Offset: 10})
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func TestListSessionChildren(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/session/ses_1/children", r.URL.Path)
+		w.Write([]byte(`[{"id":"ses_2","parentID":"ses_1"},{"id":"ses_3","parentID":"ses_1"}]`))
+	})
+
+	children, err := oc.ListSessionChildren(context.Background(), "ses_1")
+	assert.NoError(t, err)
+	assert.Len(t, children, 2)
+	assert.Equal(t, "ses_3", children[1].ID)
+}
+
+func TestDeleteSession(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodDelete, r.Method)
+		assert.Equal(t, "/session/ses_1", r.URL.Path)
+		w.Write([]byte("true"))
+	})
+
+	assert.NoError(t, oc.DeleteSession(context.Background(), "ses_1"))
+}
+
+func TestDeleteSessionNotFound(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	err := oc.DeleteSession(context.Background(), "ses_missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestGetSession(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/session/ses_2", r.URL.Path)
+		w.Write([]byte(`{"id":"ses_2","parentID":"ses_1","directory":"/work","title":"hello","time":{"created":1,"updated":2},"revert":{"messageID":"msg_1"}}`))
+	})
+
+	session, err := oc.GetSession(context.Background(), "ses_2")
+	assert.NoError(t, err)
+	assert.Equal(t, "ses_2", session.ID)
+	assert.Equal(t, "ses_1", session.ParentID)
+	assert.Equal(t, "/work", session.Directory)
+	assert.Equal(t, int64(2), session.Time.Updated)
+	if assert.NotNil(t, session.Revert) {
+		assert.Equal(t, "msg_1", session.Revert.MessageID)
+	}
+}
+
+func TestGetSessionNotFound(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := oc.GetSession(context.Background(), "ses_missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+}
+
+func TestUpdateSession(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPatch, r.Method)
+		assert.Equal(t, "/session/ses_3", r.URL.Path)
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]any{"title": "renamed"}, body)
+		w.Write([]byte(`{"id":"ses_3","title":"renamed"}`))
+	})
+
+	session, err := oc.UpdateSession(context.Background(), "ses_3", SessionUpdate{Title: "renamed"})
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", session.Title)
+}
+
+func TestSummarizeSessionInfersModel(t *testing.T) {
+	var polls atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/ses_1/message":
+			w.Write([]byte(`[{"info":{"id":"msg_1","role":"assistant","providerID":"anthropic","modelID":"claude"},"parts":[]}]`))
+		case "/session/ses_1/summarize":
+			var model Model
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&model))
+			assert.Equal(t, Model{ProviderID: "anthropic", ModelID: "claude"}, model)
+			w.Write([]byte("true"))
+		case "/session/ses_1":
+			if polls.Add(1) == 1 {
+				w.Write([]byte(`{"id":"ses_1","time":{"compacting":5}}`))
+				return
+			}
+			w.Write([]byte(`{"id":"ses_1","time":{}}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+
+	assert.NoError(t, oc.SummarizeSession(context.Background(), "ses_1"))
+	assert.Equal(t, int32(2), polls.Load())
+}
+
+func TestSummarizeSessionWithoutAssistant(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	})
+
+	err := oc.SummarizeSession(context.Background(), "ses_1")
+	assert.ErrorContains(t, err, "no assistant messages")
+}
+
+func TestInitSession(t *testing.T) {
+	var body map[string]string
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/ses_1/message":
+			w.Write([]byte(`[]`))
+		case "/config":
+			w.Write([]byte(`{"model":"anthropic/claude-sonnet-4-5"}`))
+		case "/session/ses_1/init":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Write([]byte("true"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+
+	require.NoError(t, oc.InitSession(context.Background(), "ses_1"))
+	assert.Equal(t, "anthropic", body["providerID"])
+	assert.Equal(t, "claude-sonnet-4-5", body["modelID"])
+	assert.Regexp(t, `^msg_`, body["messageID"])
+
+	require.NoError(t, oc.InitSession(context.Background(), "ses_1", Model{ProviderID: "openai", ModelID: "gpt-5"}))
+	assert.Equal(t, "gpt-5", body["modelID"])
+}
+
+func TestRevertAndUnrevert(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		switch r.URL.Path {
+		case "/session/ses_1/revert":
+			var body map[string]string
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "msg_2", body["messageID"])
+			w.Write([]byte(`{"id":"ses_1","revert":{"messageID":"msg_2","snapshot":"abc","diff":"--- a"}}`))
+		case "/session/ses_1/unrevert":
+			w.Write([]byte(`{"id":"ses_1"}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	})
+
+	session, err := oc.RevertMessage(context.Background(), "ses_1", "msg_2")
+	assert.NoError(t, err)
+	if assert.NotNil(t, session.Revert) {
+		assert.Equal(t, "msg_2", session.Revert.MessageID)
+		assert.Equal(t, "abc", session.Revert.Snapshot)
+	}
+
+	session, err = oc.UnrevertSession(context.Background(), "ses_1")
+	assert.NoError(t, err)
+	assert.Nil(t, session.Revert)
+}
@@ -0,0 +1,226 @@
+package opencode
+
+import (
+	"cmp"
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+)
+
+// sessionCachePollInterval is how often a SessionCache lists the sessions
+// once its event stream has ended.
+var sessionCachePollInterval = 5 * time.Second
+
+type SessionChangeType int
+
+const (
+	SessionCreated SessionChangeType = iota
+	SessionChanged
+	SessionRemoved
+)
+
+type SessionChange struct {
+	Type    SessionChangeType
+	Session Session
+}
+
+// SessionCache keeps the server's sessions in memory so they can be read
+// without calling ListSessions. It is primed by ListSessions, follows
+// session.updated and session.deleted events, and lists the sessions again
+// whenever the event stream reconnects, since events may have been missed
+// while it was down. Once the event stream ends for good, it lists the
+// sessions periodically instead.
+type SessionCache struct {
+	client Client
+	// refreshMu orders Refresh against applying events, so a list that was
+	// requested before an event cannot overwrite it
+	refreshMu sync.Mutex
+
+	mu        sync.Mutex
+	sessions  map[string]Session
+	listeners map[int]func(SessionChange)
+	nextID    int
+}
+
+// NewSessionCache lists the sessions and keeps them up to date until ctx is
+// done.
+func NewSessionCache(ctx context.Context, client Client) (*SessionCache, error) {
+	c := &SessionCache{
+		client:    client,
+		sessions:  make(map[string]Session),
+		listeners: make(map[int]func(SessionChange)),
+	}
+	events := client.SubscribeFiltered(ctx, EventFilter{Types: []string{EventServerConnected, EventSessionUpdated, EventSessionDeleted}})
+	if err := c.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	go func() {
+		var poll <-chan time.Time
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					if ctx.Err() != nil {
+						return
+					}
+					clientLogger(client).Warn("Event stream ended, polling sessions instead")
+					events = nil
+					ticker := time.NewTicker(sessionCachePollInterval)
+					defer ticker.Stop()
+					poll = ticker.C
+					continue
+				}
+				switch e := event.(type) {
+				case *ServerConnectedEvent:
+					// A failed refresh is retried on the next reconnect.
+					_ = c.Refresh(ctx)
+				case *SessionUpdatedEvent:
+					c.apply(e.Info, false)
+				case *SessionDeletedEvent:
+					c.apply(e.Info, true)
+				}
+			case <-poll:
+				if err := c.Refresh(ctx); err != nil && ctx.Err() == nil {
+					clientLogger(client).Debug("Failed to poll sessions", "err", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+// Refresh lists the sessions again and reports the differences to the
+// OnChange callbacks.
+func (c *SessionCache) Refresh(ctx context.Context) error {
+	changes, listeners, err := c.refresh(ctx)
+	for _, change := range changes {
+		for _, fn := range listeners {
+			fn(change)
+		}
+	}
+	return err
+}
+
+func (c *SessionCache) refresh(ctx context.Context) ([]SessionChange, []func(SessionChange), error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	sessions, err := c.client.ListSessions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var changes []SessionChange
+	listed := make(map[string]Session, len(sessions))
+	for _, session := range sessions {
+		old, ok := c.sessions[session.ID]
+		if ok && old.Time.Updated > session.Time.Updated {
+			session = old
+		}
+		listed[session.ID] = session
+		if !ok {
+			changes = append(changes, SessionChange{Type: SessionCreated, Session: session})
+		} else if !reflect.DeepEqual(old, session) {
+			changes = append(changes, SessionChange{Type: SessionChanged, Session: session})
+		}
+	}
+	for id, session := range c.sessions {
+		if _, ok := listed[id]; !ok {
+			changes = append(changes, SessionChange{Type: SessionRemoved, Session: session})
+		}
+	}
+	c.sessions = listed
+	return changes, c.callbacks(), nil
+}
+
+func (c *SessionCache) apply(session Session, deleted bool) {
+	change, ok, listeners := c.merge(session, deleted)
+	if !ok {
+		return
+	}
+	for _, fn := range listeners {
+		fn(change)
+	}
+}
+
+// merge applies an event and returns the change it made, if any.
+func (c *SessionCache) merge(session Session, deleted bool) (SessionChange, bool, []func(SessionChange)) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	old, ok := c.sessions[session.ID]
+	change := SessionChange{Type: SessionChanged, Session: session}
+	switch {
+	case deleted:
+		if !ok {
+			return change, false, nil
+		}
+		change.Type = SessionRemoved
+		delete(c.sessions, session.ID)
+	case ok && old.Time.Updated > session.Time.Updated:
+		return change, false, nil
+	default:
+		if !ok {
+			change.Type = SessionCreated
+		}
+		c.sessions[session.ID] = session
+	}
+	return change, true, c.callbacks()
+}
+
+func (c *SessionCache) callbacks() []func(SessionChange) {
+	ids := make([]int, 0, len(c.listeners))
+	for id := range c.listeners {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	listeners := make([]func(SessionChange), len(ids))
+	for i, id := range ids {
+		listeners[i] = c.listeners[id]
+	}
+	return listeners
+}
+
+// OnChange calls fn for every session that is created, changed or removed
+// from now on, in the order the changes are seen. Calling remove stops
+// further calls.
+func (c *SessionCache) OnChange(fn func(SessionChange)) (remove func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextID
+	c.nextID++
+	c.listeners[id] = fn
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+// Sessions returns the cached sessions, most recently updated first.
+func (c *SessionCache) Sessions() []Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sessions := make([]Session, 0, len(c.sessions))
+	for _, session := range c.sessions {
+		sessions = append(sessions, session)
+	}
+	slices.SortFunc(sessions, func(a, b Session) int {
+		return cmp.Or(cmp.Compare(b.Time.Updated, a.Time.Updated), cmp.Compare(b.ID, a.ID))
+	})
+	return sessions
+}
+
+// Session returns a cached session by ID.
+func (c *SessionCache) Session(sessionID string) (Session, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	session, ok := c.sessions[sessionID]
+	return session, ok
+}
@@ -0,0 +1,171 @@
+package opencode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionCache(t *testing.T) {
+	events := make(chan string)
+	var lists atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case event := <-events:
+					fmt.Fprintf(w, "data: %s\n\n", event)
+					w.(http.Flusher).Flush()
+				}
+			}
+		case "/session":
+			if lists.Add(1) == 1 {
+				w.Write([]byte(`[{"id":"ses_1","title":"one","time":{"updated":1}},{"id":"ses_2","title":"two","time":{"updated":2}}]`))
+			} else {
+				w.Write([]byte(`[{"id":"ses_2","title":"two","time":{"updated":2}},{"id":"ses_4","title":"four","time":{"updated":4}}]`))
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := NewSessionCache(ctx, oc)
+	require.NoError(t, err)
+	changes := make(chan SessionChange, 10)
+	cache.OnChange(func(change SessionChange) { changes <- change })
+	next := func() SessionChange {
+		t.Helper()
+		select {
+		case change := <-changes:
+			return change
+		case <-time.After(time.Second):
+			t.Fatal("no change")
+			return SessionChange{}
+		}
+	}
+
+	sessions := cache.Sessions()
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "ses_2", sessions[0].ID)
+
+	events <- `{"type":"session.updated","properties":{"info":{"id":"ses_3","title":"three","time":{"updated":3}}}}`
+	change := next()
+	assert.Equal(t, SessionCreated, change.Type)
+	assert.Equal(t, "ses_3", change.Session.ID)
+
+	events <- `{"type":"session.updated","properties":{"info":{"id":"ses_1","title":"renamed","time":{"updated":5}}}}`
+	change = next()
+	assert.Equal(t, SessionChanged, change.Type)
+	session, ok := cache.Session("ses_1")
+	require.True(t, ok)
+	assert.Equal(t, "renamed", session.Title)
+
+	// Stale updates are ignored.
+	events <- `{"type":"session.updated","properties":{"info":{"id":"ses_1","title":"one","time":{"updated":1}}}}`
+	events <- `{"type":"session.deleted","properties":{"info":{"id":"ses_3"}}}`
+	change = next()
+	assert.Equal(t, SessionRemoved, change.Type)
+	assert.Equal(t, "ses_3", change.Session.ID)
+	assert.Equal(t, "ses_1", cache.Sessions()[0].ID)
+
+	// A reconnect lists the sessions again.
+	events <- `{"type":"server.connected","properties":{}}`
+	got := map[string]SessionChangeType{}
+	for range 2 {
+		change := next()
+		got[change.Session.ID] = change.Type
+	}
+	assert.Equal(t, map[string]SessionChangeType{"ses_1": SessionRemoved, "ses_4": SessionCreated}, got)
+	assert.Len(t, cache.Sessions(), 2)
+}
+
+func TestSessionCacheRefreshKeepsNewerEvents(t *testing.T) {
+	events := make(chan string)
+	var lists atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case event := <-events:
+					fmt.Fprintf(w, "data: %s\n\n", event)
+					w.(http.Flusher).Flush()
+				}
+			}
+		case "/session":
+			if lists.Add(1) > 1 {
+				// The session is renamed while the list is on its way
+				events <- `{"type":"session.updated","properties":{"info":{"id":"ses_1","title":"new","time":{"updated":9}}}}`
+				time.Sleep(100 * time.Millisecond)
+			}
+			w.Write([]byte(`[{"id":"ses_1","title":"old","time":{"updated":1}}]`))
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := NewSessionCache(ctx, oc)
+	require.NoError(t, err)
+	renamed := make(chan struct{})
+	cache.OnChange(func(change SessionChange) {
+		if change.Session.Title == "new" {
+			close(renamed)
+		}
+	})
+
+	require.NoError(t, cache.Refresh(ctx))
+	select {
+	case <-renamed:
+	case <-time.After(time.Second):
+		t.Fatal("update was lost")
+	}
+	session, _ := cache.Session("ses_1")
+	assert.Equal(t, "new", session.Title)
+}
+
+func TestSessionCachePollsWhenStreamEnds(t *testing.T) {
+	interval := sessionCachePollInterval
+	sessionCachePollInterval = 20 * time.Millisecond
+	defer func() { sessionCachePollInterval = interval }()
+
+	var lists atomic.Int32
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/event":
+			// The stream ends right away and is not reconnected
+			sseHandler()(w, r)
+		case "/session":
+			if lists.Add(1) == 1 {
+				w.Write([]byte(`[{"id":"ses_1","title":"one","time":{"updated":1}}]`))
+			} else {
+				w.Write([]byte(`[{"id":"ses_2","title":"two","time":{"updated":2}}]`))
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache, err := NewSessionCache(ctx, oc)
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, ok := cache.Session("ses_2")
+		return ok
+	}, 2*time.Second, 10*time.Millisecond)
+	_, ok := cache.Session("ses_1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,65 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ServerSpec is the subset of the server's OpenAPI document needed to detect
+// which endpoints it supports.
+type ServerSpec struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	// Paths maps paths such as "/session/{id}" to their operations by
+	// lowercase method.
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// ServerSpec fetches the server's OpenAPI document from /doc.
+func (oc *OpenCode) ServerSpec(ctx context.Context) (*ServerSpec, error) {
+	var spec ServerSpec
+	if err := oc.do(ctx, http.MethodGet, "/doc", nil, &spec); err != nil {
+		return nil, fmt.Errorf("failed to get server spec: %w", err)
+	}
+	return &spec, nil
+}
+
+// Supports reports whether the server has an endpoint, given as a path like
+// "/session/:id/shell" or as "POST /session/:id/shell" to also match the
+// method. Parameters may be written as :name or {name}.
+func (s *ServerSpec) Supports(endpoint string) bool {
+	method, path, ok := strings.Cut(endpoint, " ")
+	if !ok {
+		method, path = "", endpoint
+	}
+	want := normalizeSpecPath(path)
+	for specPath, operations := range s.Paths {
+		if normalizeSpecPath(specPath) != want {
+			continue
+		}
+		if method == "" {
+			return true
+		}
+		_, ok := operations[strings.ToLower(method)]
+		return ok
+	}
+	return false
+}
+
+// normalizeSpecPath replaces path parameters so "/session/:id" and
+// "/session/{sessionID}" compare equal.
+func normalizeSpecPath(path string) string {
+	segments := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,30 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpec(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/doc", r.URL.Path)
+		w.Write([]byte(`{"openapi":"3.1.1","info":{"title":"opencode","version":"0.15.0"},"paths":{
+			"/session":{"get":{},"post":{}},
+			"/session/{id}/shell":{"post":{}},
+			"/find/symbol":{"get":{}}
+		}}`))
+	})
+
+	spec, err := oc.ServerSpec(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "0.15.0", spec.Info.Version)
+	assert.True(t, spec.Supports("/session"))
+	assert.True(t, spec.Supports("POST /session/:id/shell"))
+	assert.True(t, spec.Supports("post /session/{sessionID}/shell/"))
+	assert.False(t, spec.Supports("GET /session/:id/shell"))
+	assert.False(t, spec.Supports("/session/:id/init"))
+}
@@ -0,0 +1,332 @@
+package opencode
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"time"
+)
+
+// StreamEvents delivers server events to callback until the stream ends or
+// ctx is cancelled, in which case the request is closed and ctx.Err() is
+// returned. It returns ErrClosed once Close has been called.
+func (oc *OpenCode) StreamEvents(ctx context.Context, callback func(Event)) error {
+	done, ok := oc.trackStream()
+	if !ok {
+		return ErrClosed
+	}
+	defer done()
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop := context.AfterFunc(oc.closeCtx, func() { cancel(ErrClosed) })
+	defer stop()
+
+	err := oc.streamEvents(ctx, nil, callback)
+	if errors.Is(context.Cause(ctx), ErrClosed) {
+		return ErrClosed
+	}
+	return err
+}
+
+// Events streams server events into a channel. Both channels are closed when
+// the stream ends; the error channel only receives failures, not cancellation.
+func (oc *OpenCode) Events(ctx context.Context, maybeBufferSize ...int) (<-chan Event, <-chan error) {
+	bufferSize := 64
+	if len(maybeBufferSize) > 0 {
+		bufferSize = maybeBufferSize[0]
+	}
+
+	events := make(chan Event, bufferSize)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(events)
+		defer close(errs)
+		err := oc.StreamEvents(ctx, func(event Event) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+	return events, errs
+}
+
+func (oc *OpenCode) EventSeq(ctx context.Context) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		events, errs := oc.Events(ctx)
+		for event := range events {
+			if !yield(event, nil) {
+				return
+			}
+		}
+		if err := <-errs; err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+type ReconnectPolicy struct {
+	// MaxAttempts limits consecutive failed reconnects; zero means unlimited.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	OnReconnect    func(attempt int, err error)
+}
+
+func (oc *OpenCode) streamEvents(ctx context.Context, onConnect func(), callback func(Event)) error {
+	policy := oc.config.EventReconnect
+	var lastEventID string
+	if policy == nil {
+		_, err := oc.streamOnce(ctx, &lastEventID, onConnect, callback)
+		return err
+	}
+
+	backoff := policy.InitialBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+	maxBackoff := policy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	delay := backoff
+	for attempt := 0; ; {
+		received, err := oc.streamOnce(ctx, &lastEventID, onConnect, callback)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if received {
+			attempt, delay = 0, backoff
+		}
+		attempt++
+		if policy.MaxAttempts > 0 && attempt > policy.MaxAttempts {
+			if err == nil {
+				err = errors.New("event stream closed")
+			}
+			return fmt.Errorf("event stream reconnect gave up after %d attempts: %w", policy.MaxAttempts, err)
+		}
+
+		oc.log.Info("Reconnecting to event stream", "attempt", attempt, "delay", delay, "err", err)
+		if policy.OnReconnect != nil {
+			policy.OnReconnect(attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		if oc.config.Metrics != nil {
+			oc.config.Metrics.ObserveReconnect()
+		}
+		delay = min(delay*2, maxBackoff)
+	}
+}
+
+var ErrEventStreamIdle = errors.New("event stream idle")
+
+// DefaultMaxEventSize is the default for Config.MaxEventSize.
+const DefaultMaxEventSize = 16 << 20
+
+// lineReader reads lines of any length without holding more than max bytes
+// of one line in memory.
+type lineReader struct {
+	r   *bufio.Reader
+	max int
+	buf []byte
+}
+
+func newLineReader(r io.Reader, max int) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), max: max}
+}
+
+// readLine returns the next line without its line ending, truncated to max
+// bytes unless max is negative, and the line's full length.
+func (l *lineReader) readLine() ([]byte, int, error) {
+	l.buf = l.buf[:0]
+	size := 0
+	var chunk []byte
+	err := bufio.ErrBufferFull
+	for err == bufio.ErrBufferFull {
+		chunk, err = l.r.ReadSlice('\n')
+		size += len(chunk)
+		if l.max < 0 {
+			l.buf = append(l.buf, chunk...)
+		} else if len(l.buf) < l.max {
+			l.buf = append(l.buf, chunk[:min(len(chunk), l.max-len(l.buf))]...)
+		}
+	}
+	if err != nil && (err != io.EOF || size == 0) {
+		return nil, 0, err
+	}
+	if bytes.HasSuffix(chunk, []byte("\r\n")) {
+		size -= 2
+	} else if bytes.HasSuffix(chunk, []byte("\n")) {
+		size--
+	}
+	line := bytes.TrimSuffix(bytes.TrimSuffix(l.buf, []byte("\n")), []byte("\r"))
+	return line, size, nil
+}
+
+type idleReader struct {
+	r       io.Reader
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+func (oc *OpenCode) streamOnce(ctx context.Context, lastEventID *string, onConnect func(), callback func(Event)) (bool, error) {
+	reqCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	req, err := oc.newRequest(reqCtx, http.MethodGet, "/event", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	// Half-open connections never error, so give up on streams that stay silent
+	timeout := oc.config.EventIdleTimeout
+	var timer *time.Timer
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() { cancel(ErrEventStreamIdle) })
+		defer timer.Stop()
+	}
+	idleErr := func() error {
+		return fmt.Errorf("%w: no data received for %s", ErrEventStreamIdle, timeout)
+	}
+
+	resp, err := oc.sendWith(oc.streamClient, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if errors.Is(context.Cause(reqCtx), ErrEventStreamIdle) {
+			return false, idleErr()
+		}
+		return false, fmt.Errorf("failed to connect to event stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, newAPIError(resp)
+	}
+	oc.log.Debug("Connected to event stream", "addr", oc.Addr())
+	if onConnect != nil {
+		onConnect()
+	}
+
+	var body io.Reader = resp.Body
+	if timer != nil {
+		body = &idleReader{r: resp.Body, timer: timer, timeout: timeout}
+	}
+
+	received, readErr := oc.readEvents(body, lastEventID, callback)
+
+	if ctx.Err() != nil {
+		return received, ctx.Err()
+	}
+	if errors.Is(context.Cause(reqCtx), ErrEventStreamIdle) {
+		return received, idleErr()
+	}
+	if readErr != nil && !errors.Is(readErr, context.Canceled) {
+		return received, fmt.Errorf("failed to read event stream: %w", readErr)
+	}
+	oc.log.Debug("Event stream closed", "addr", oc.Addr())
+	return received, nil
+}
+
+// readEvents parses the SSE stream in body until it ends. The data buffer is
+// reused across events, which is safe because ParseEvent copies what it keeps.
+func (oc *OpenCode) readEvents(body io.Reader, lastEventID *string, callback func(Event)) (bool, error) {
+	maxSize := oc.config.MaxEventSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxEventSize
+	}
+	// Leave room for the field name so data lines at the limit stay whole
+	reader := newLineReader(body, maxSize+64)
+	if maxSize < 0 {
+		reader = newLineReader(body, -1)
+	}
+	received := false
+	var data bytes.Buffer
+	eventSize, oversized := 0, false
+	for {
+		line, lineSize, err := reader.readLine()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			return received, err
+		}
+		switch {
+		case lineSize == 0:
+			if oversized {
+				oc.log.Warn("Skipping event larger than MaxEventSize", "size", eventSize, "max", maxSize)
+				if oc.config.OnEventTooLarge != nil {
+					oc.config.OnEventTooLarge(eventSize)
+				}
+			}
+			if data.Len() == 0 || oversized {
+				data.Reset()
+				eventSize, oversized = 0, false
+				continue
+			}
+			event, err := ParseEvent(data.Bytes())
+			data.Reset()
+			eventSize = 0
+			if err != nil {
+				oc.log.Warn("Skipping malformed event", "err", err)
+				continue
+			}
+			received = true
+			if oc.config.Metrics != nil {
+				oc.config.Metrics.ObserveEvent(event)
+			}
+			callback(event)
+		case bytes.HasPrefix(line, []byte("data:")):
+			eventSize += lineSize - len("data:")
+			if len(line) > len("data:") && line[len("data:")] == ' ' {
+				eventSize--
+			}
+			if oversized || maxSize > 0 && eventSize > maxSize {
+				// Drop what was collected, but keep counting the size
+				oversized = true
+				data.Reset()
+				continue
+			}
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			value := line[len("data:"):]
+			if len(value) > 0 && value[0] == ' ' {
+				value = value[1:]
+			}
+			data.Write(value)
+		case bytes.HasPrefix(line, []byte("id:")):
+			*lastEventID = string(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("id:")), []byte(" ")))
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package opencode
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sseHandler(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for _, event := range events {
+			w.Write([]byte("data: " + event + "\n\n"))
+		}
+		w.(http.Flusher).Flush()
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	oc := newTestServer(t, sseHandler(
+		`{"type":"server.connected","properties":{}}`,
+		`garbage`,
+		`{"type":"session.status","properties":{"sessionID":"ses_1","status":{"type":"idle"}}}`,
+	))
+
+	var events []Event
+	err := oc.StreamEvents(context.Background(), func(event Event) {
+		events = append(events, event)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Event{
+		&ServerConnectedEvent{},
+		&SessionStatusEvent{SessionID: "ses_1", Status: SessionStatus{Type: "idle"}},
+	}, events)
+}
+
+func TestStreamEventsCancel(t *testing.T) {
+	closed := make(chan struct{})
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		sseHandler(`{"type":"server.connected","properties":{}}`)(w, r)
+		<-r.Context().Done()
+		close(closed)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- oc.StreamEvents(ctx, func(event Event) {
+			cancel()
+		})
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("StreamEvents did not return after cancel")
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("event stream request was not torn down")
+	}
+}
+
+func TestEvents(t *testing.T) {
+	oc := newTestServer(t, sseHandler(
+		`{"type":"server.connected","properties":{}}`,
+		`{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`,
+	))
+
+	events, errs := oc.Events(context.Background(), 0)
+	var types []string
+	for event := range events {
+		types = append(types, event.EventType())
+	}
+	assert.Equal(t, []string{"server.connected", "message.removed"}, types)
+	assert.NoError(t, <-errs)
+}
+
+func TestEventsError(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	events, errs := oc.Events(context.Background())
+	for range events {
+	}
+	assert.EqualError(t, <-errs, "unexpected status code: 503")
+}
+
+func TestEventSeqBreak(t *testing.T) {
+	closed := make(chan struct{})
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		sseHandler(
+			`{"type":"server.connected","properties":{}}`,
+			`{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`,
+		)(w, r)
+		<-r.Context().Done()
+		close(closed)
+	})
+
+	var types []string
+	for event, err := range oc.EventSeq(context.Background()) {
+		assert.NoError(t, err)
+		types = append(types, event.EventType())
+		if len(types) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"server.connected", "message.removed"}, types)
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("event stream request was not torn down")
+	}
+}
+
+func TestEventSeqError(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	for event, err := range oc.EventSeq(context.Background()) {
+		assert.Nil(t, event)
+		assert.EqualError(t, err, "unexpected status code: 502")
+	}
+}
+
+func TestStreamEventsReconnect(t *testing.T) {
+	var lastEventIDs []string
+	var connections int
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		connections++
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		switch connections {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("id: 7\ndata: {\"type\":\"server.connected\",\"properties\":{}}\n\n"))
+		case 3:
+			sseHandler(`{"type":"message.removed","properties":{"sessionID":"ses_1","messageID":"msg_1"}}`)(w, r)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	var attempts []int
+	oc.config.EventReconnect = &ReconnectPolicy{
+		MaxAttempts:    2,
+		InitialBackoff: time.Millisecond,
+		OnReconnect:    func(attempt int, err error) { attempts = append(attempts, attempt) },
+	}
+
+	var types []string
+	err := oc.StreamEvents(context.Background(), func(event Event) {
+		types = append(types, event.EventType())
+	})
+	assert.ErrorContains(t, err, "gave up after 2 attempts")
+	assert.Equal(t, []string{"server.connected", "message.removed"}, types)
+	assert.Equal(t, []string{"", "7", "7", "7", "7"}, lastEventIDs)
+	assert.Equal(t, []int{1, 2, 1, 2}, attempts)
+}
+
+func TestStreamEventsIdleTimeout(t *testing.T) {
+	oc := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for range 3 {
+			w.Write([]byte(": keep-alive\n\n"))
+			w.(http.Flusher).Flush()
+			time.Sleep(20 * time.Millisecond)
+		}
+		w.Write([]byte("data: {\"type\":\"server.connected\",\"properties\":{}}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+	oc.config.EventIdleTimeout = 50 * time.Millisecond
+
+	var types []string
+	err := oc.StreamEvents(context.Background(), func(event Event) {
+		types = append(types, event.EventType())
+	})
+	assert.ErrorIs(t, err, ErrEventStreamIdle)
+	assert.Equal(t, []string{"server.connected"}, types)
+}
+
+func TestLineReader(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	input := "short\r\n" + long + "\n\nlast"
+
+	r := newLineReader(strings.NewReader(input), -1)
+	for _, want := range []string{"short", long, "", "last"} {
+		line, size, err := r.readLine()
+		require.NoError(t, err)
+		assert.Equal(t, want, string(line))
+		assert.Equal(t, len(want), size)
+	}
+	_, _, err := r.readLine()
+	assert.ErrorIs(t, err, io.EOF)
+
+	r = newLineReader(strings.NewReader(input), 10)
+	r.readLine()
+	line, size, err := r.readLine()
+	require.NoError(t, err)
+	assert.Equal(t, long[:10], string(line))
+	assert.Equal(t, len(long), size)
+}
+
+func TestStreamEventsTooLarge(t *testing.T) {
+	big := `{"type":"file.edited","properties":{"file":"` + strings.Repeat("a", 2<<20) + `"}}`
+	oc := newTestServer(t, sseHandler(
+		`{"type":"file.edited","properties":{"file":"before.go"}}`,
+		big,
+		`{"type":"file.edited","properties":{"file":"after.go"}}`,
+	))
+
+	var files []string
+	collect := func(event Event) { files = append(files, event.(*FileEditedEvent).File) }
+	var skipped []int
+	oc.config.MaxEventSize = 1 << 20
+	oc.config.OnEventTooLarge = func(size int) { skipped = append(skipped, size) }
+	require.NoError(t, oc.StreamEvents(context.Background(), collect))
+	assert.Equal(t, []string{"before.go", "after.go"}, files)
+	assert.Equal(t, []int{len(big)}, skipped)
+
+	// The default limit is large enough for file contents in tool output
+	files = nil
+	oc.config.MaxEventSize = 0
+	require.NoError(t, oc.StreamEvents(context.Background(), collect))
+	assert.Len(t, files, 3)
+}
+
+func BenchmarkReadEvents(b *testing.B) {
+	var sse strings.Builder
+	for range 1000 {
+		sse.WriteString("data: ")
+		sse.Write(benchmarkEvents["part"])
+		sse.WriteString("\n\n")
+	}
+	input := sse.String()
+	oc := New(Config{})
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	var events int
+	var lastEventID string
+	for b.Loop() {
+		if _, err := oc.readEvents(strings.NewReader(input), &lastEventID, func(Event) { events++ }); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(events)/b.Elapsed().Seconds(), "events/s")
+	b.ReportMetric(float64(testing.AllocsPerRun(1, func() {
+		oc.readEvents(strings.NewReader(input), &lastEventID, func(Event) {})
+	}))/1000, "allocs/event")
+}
@@ -0,0 +1,169 @@
+package opencode
+
+import (
+	"context"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RestartPolicy makes OpenCode restart the server when it exits on its own.
+type RestartPolicy struct {
+	// MaxRestarts limits restarts within ResetWindow before giving up.
+	// Defaults to 5.
+	MaxRestarts int
+	// ResetWindow is how long a restart counts against MaxRestarts. Defaults
+	// to 5 minutes.
+	ResetWindow    time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// ReadyTimeout bounds the readiness check after each restart. Defaults to
+	// 15 seconds.
+	ReadyTimeout time.Duration
+	OnEvent      func(SupervisorEvent)
+}
+
+type SupervisorEventKind string
+
+const (
+	SupervisorRestarting SupervisorEventKind = "restarting"
+	SupervisorRestarted  SupervisorEventKind = "restarted"
+	SupervisorFailed     SupervisorEventKind = "failed"
+	SupervisorGaveUp     SupervisorEventKind = "gave_up"
+)
+
+type SupervisorEvent struct {
+	Kind SupervisorEventKind
+	// Attempt counts restarts within the reset window, starting at 1.
+	Attempt int
+	// Exit describes the crash that triggered the restart.
+	Exit *ExitState
+	Err  error
+}
+
+func (p *RestartPolicy) emit(event SupervisorEvent) {
+	if p.OnEvent != nil {
+		p.OnEvent(event)
+	}
+}
+
+func (p *RestartPolicy) backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// supervise waits for cmd to exit and restarts opencode unless the exit was
+// requested through Stop or Restart. Every started process gets its own
+// supervisor, so this returns once a replacement is up or stop is closed.
+func (oc *OpenCode) supervise(cmd *exec.Cmd, exited, stop <-chan struct{}) {
+	<-exited
+	policy := oc.config.AutoRestart
+	maxRestarts := policy.MaxRestarts
+	if maxRestarts <= 0 {
+		maxRestarts = 5
+	}
+	window := policy.ResetWindow
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	readyTimeout := policy.ReadyTimeout
+	if readyTimeout <= 0 {
+		readyTimeout = 15 * time.Second
+	}
+	state := oc.ExitState()
+
+	for {
+		oc.mu.Lock()
+		if oc.cmd != cmd {
+			oc.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		oc.restarts = slices.DeleteFunc(oc.restarts, func(t time.Time) bool {
+			return now.Sub(t) > window
+		})
+		if len(oc.restarts) >= maxRestarts {
+			oc.mu.Unlock()
+			oc.log.Error("OpenCode keeps crashing, giving up", "restarts", maxRestarts, "window", window)
+			policy.emit(SupervisorEvent{Kind: SupervisorGaveUp, Exit: state})
+			return
+		}
+		oc.restarts = append(oc.restarts, now)
+		attempt := len(oc.restarts)
+		oc.mu.Unlock()
+
+		backoff := policy.backoff(attempt)
+		oc.log.Warn("OpenCode exited unexpectedly, restarting", "code", state.Code, "attempt", attempt, "backoff", backoff)
+		policy.emit(SupervisorEvent{Kind: SupervisorRestarting, Attempt: attempt, Exit: state})
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			oc.log.Debug("OpenCode stopped during restart backoff")
+			return
+		}
+
+		oc.mu.Lock()
+		if oc.cmd != cmd {
+			oc.mu.Unlock()
+			return
+		}
+		oc.cmd = nil
+		err := oc.start(oc.currentPort())
+		if err != nil {
+			// Keep the crashed command so Stop still ends supervision
+			oc.cmd = cmd
+			oc.mu.Unlock()
+			oc.log.Error("Failed to restart OpenCode", "err", err)
+			policy.emit(SupervisorEvent{Kind: SupervisorFailed, Attempt: attempt, Exit: state, Err: err})
+			continue
+		}
+		restarted := oc.cmd
+		oc.mu.Unlock()
+
+		// WaitForReady gives up early if the new process exits again
+		if err := oc.WaitForReady(context.Background(), readyTimeout); err != nil {
+			// The new process's supervisor takes over once it is gone
+			oc.log.Error("Restarted OpenCode did not become ready", "err", err)
+			policy.emit(SupervisorEvent{Kind: SupervisorFailed, Attempt: attempt, Exit: state, Err: err})
+			oc.mu.Lock()
+			if oc.cmd == restarted {
+				killProcess(restarted.Process)
+			}
+			oc.mu.Unlock()
+			return
+		}
+
+		oc.log.Info("OpenCode restarted after crash", "addr", oc.Addr(), "attempt", attempt)
+		policy.emit(SupervisorEvent{Kind: SupervisorRestarted, Attempt: attempt, Exit: state})
+		if oc.config.Metrics != nil {
+			oc.config.Metrics.ObserveRestart()
+		}
+		if oc.config.OnRestart != nil {
+			oc.config.OnRestart()
+		}
+		return
+	}
+}
+
+func (oc *OpenCode) currentPort() int {
+	_, portStr, err := net.SplitHostPort(oc.Addr())
+	if err != nil {
+		return 0
+	}
+	port, _ := strconv.Atoi(portStr)
+	return port
+}
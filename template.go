@@ -0,0 +1,90 @@
+package opencode
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+type ConfigRender int
+
+const (
+	// RenderEnv expands $VAR and ${VAR} in ConfigFS files, leaving unset
+	// variables empty.
+	RenderEnv ConfigRender = iota
+	// RenderTemplate executes ConfigFS files as text/template and fails on
+	// missing keys and unset variables.
+	RenderTemplate
+)
+
+// renderConfigFile renders one ConfigFS file according to Config.ConfigRender.
+// Files matching Config.ConfigVerbatim and binary files are copied as is.
+func (oc *OpenCode) renderConfigFile(name string, content []byte) ([]byte, error) {
+	if bytes.IndexByte(content, 0) >= 0 {
+		return content, nil
+	}
+	for _, pattern := range oc.config.ConfigVerbatim {
+		if ok, _ := path.Match(pattern, name); ok {
+			return content, nil
+		}
+	}
+
+	if oc.config.ConfigRender != RenderTemplate {
+		return []byte(os.Expand(string(content), oc.getenv)), nil
+	}
+
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(oc.templateFuncs()).
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	data := oc.config.TemplateData
+	if data == nil {
+		data = map[string]any{"Env": oc.envMap()}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// templateFuncs provides env, which fails for unset variables, and envOr,
+// which falls back to a default, on top of Config.TemplateFuncs.
+func (oc *OpenCode) templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		"env": func(key string) (string, error) {
+			value, ok := oc.lookupEnv(key)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", key)
+			}
+			return value, nil
+		},
+		"envOr": func(key, fallback string) string {
+			if value, ok := oc.lookupEnv(key); ok {
+				return value
+			}
+			return fallback
+		},
+	}
+	for name, fn := range oc.config.TemplateFuncs {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+// envMap returns the server's environment as a map for templates.
+func (oc *OpenCode) envMap() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range oc.environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
@@ -0,0 +1,55 @@
+package opencode
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderConfigFileEnv(t *testing.T) {
+	oc := New(Config{Env: map[string]string{"MODEL": "anthropic/claude"}})
+	out, err := oc.renderConfigFile("config.json", []byte(`{"model":"${MODEL}","x":"$UNSET_VAR_FOR_TEST"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"model":"anthropic/claude","x":""}`, string(out))
+}
+
+func TestRenderConfigFileTemplate(t *testing.T) {
+	oc := New(Config{
+		ConfigRender:  RenderTemplate,
+		Env:           map[string]string{"MODEL": "anthropic/claude"},
+		TemplateFuncs: template.FuncMap{"upper": strings.ToUpper},
+	})
+	out, err := oc.renderConfigFile("config.json", []byte(`{"model":"{{env "MODEL"}}","small":"{{.Env.MODEL | upper}}","level":"{{envOr "UNSET_VAR_FOR_TEST" "info"}}"}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"model":"anthropic/claude","small":"ANTHROPIC/CLAUDE","level":"info"}`, string(out))
+
+	_, err = oc.renderConfigFile("config.json", []byte(`{{env "UNSET_VAR_FOR_TEST"}}`))
+	assert.ErrorContains(t, err, "environment variable UNSET_VAR_FOR_TEST is not set")
+	_, err = oc.renderConfigFile("config.json", []byte(`{{.Env.UNSET_VAR_FOR_TEST}}`))
+	assert.ErrorContains(t, err, "failed to render template config.json")
+}
+
+func TestRenderConfigFileTemplateData(t *testing.T) {
+	oc := New(Config{ConfigRender: RenderTemplate, TemplateData: map[string]string{"Agent": "review"}})
+	out, err := oc.renderConfigFile("agent.md", []byte(`{{.Agent}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "review", string(out))
+
+	_, err = oc.renderConfigFile("agent.md", []byte(`{{.Missing}}`))
+	assert.Error(t, err)
+}
+
+func TestRenderConfigFileVerbatim(t *testing.T) {
+	oc := New(Config{ConfigRender: RenderTemplate, ConfigVerbatim: []string{"prompts/*.md"}})
+	out, err := oc.renderConfigFile("prompts/raw.md", []byte(`{{not a template}}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{{not a template}}`, string(out))
+
+	binary := []byte("\x00${HOME}{{")
+	out, err = oc.renderConfigFile("icon.png", binary)
+	require.NoError(t, err)
+	assert.Equal(t, binary, out)
+}
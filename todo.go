@@ -0,0 +1,36 @@
+package opencode
+
+import "encoding/json"
+
+type Todo struct {
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	// Status is one of "pending", "in_progress", "completed" or "cancelled".
+	Status string `json:"status"`
+	// Priority is one of "high", "medium" or "low".
+	Priority string `json:"priority"`
+}
+
+type TodoUpdatedEvent struct {
+	SessionID string `json:"sessionID"`
+	Todos     []Todo `json:"todos"`
+}
+
+func (TodoUpdatedEvent) EventType() string { return EventTodoUpdated }
+
+// Todos returns the plan written by a todowrite tool call, or nil for any
+// other tool.
+func (p *ToolPart) Todos() []Todo {
+	if p.Tool != "todowrite" || p.Metadata["todos"] == nil {
+		return nil
+	}
+	data, err := json.Marshal(p.Metadata["todos"])
+	if err != nil {
+		return nil
+	}
+	var todos []Todo
+	if err := json.Unmarshal(data, &todos); err != nil {
+		return nil
+	}
+	return todos
+}
@@ -0,0 +1,31 @@
+package opencode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTodoUpdatedEvent(t *testing.T) {
+	event, err := ParseEvent([]byte(`{"type":"todo.updated","properties":{"sessionID":"ses_1","todos":[
+		{"id":"1","content":"write tests","status":"in_progress","priority":"high"},
+		{"id":"2","content":"ship it","status":"pending","priority":"low"}
+	]}}`))
+	require.NoError(t, err)
+	todos := event.(*TodoUpdatedEvent)
+	assert.Equal(t, "ses_1", todos.SessionID)
+	assert.Equal(t, []Todo{
+		{ID: "1", Content: "write tests", Status: "in_progress", Priority: "high"},
+		{ID: "2", Content: "ship it", Status: "pending", Priority: "low"},
+	}, todos.Todos)
+	assert.True(t, EventFilter{SessionID: "ses_1"}.Match(event))
+}
+
+func TestToolPartTodos(t *testing.T) {
+	part, err := decodePart([]byte(`{"id":"prt_1","type":"tool","tool":"todowrite","state":{"status":"completed","metadata":{"todos":[{"id":"1","content":"plan","status":"completed","priority":"medium"}]}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, []Todo{{ID: "1", Content: "plan", Status: "completed", Priority: "medium"}}, part.(*ToolPart).Todos())
+
+	assert.Nil(t, (&ToolPart{Tool: "bash"}).Todos())
+}
@@ -0,0 +1,36 @@
+package opencode
+
+import "context"
+
+type ToolUpdate struct {
+	Part     *ToolPart
+	Previous ToolStatus
+}
+
+// ToolUpdates reports tool calls in a session each time they change state,
+// skipping the repeated updates the server sends while a tool streams output.
+func (oc *OpenCode) ToolUpdates(ctx context.Context, sessionID string) <-chan ToolUpdate {
+	events := oc.SubscribeFiltered(ctx, EventFilter{SessionID: sessionID, Types: []string{EventMessagePartUpdated}})
+	out := make(chan ToolUpdate, cap(events))
+	go func() {
+		defer close(out)
+		states := map[string]ToolStatus{}
+		for event := range events {
+			part, ok := event.(*MessagePartUpdatedEvent).Part.(*ToolPart)
+			if !ok || states[part.ID] == part.State {
+				continue
+			}
+			update := ToolUpdate{Part: part, Previous: states[part.ID]}
+			if part.Done() {
+				delete(states, part.ID)
+			} else {
+				states[part.ID] = part.State
+			}
+			select {
+			case out <- update:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
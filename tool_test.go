@@ -0,0 +1,53 @@
+package opencode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolPartRoundTrip(t *testing.T) {
+	data := `{"id":"prt_1","sessionID":"ses_1","messageID":"msg_1","type":"tool","callID":"call_1","tool":"bash","state":{"status":"error","input":{"command":"false"},"error":"exit 1"}}`
+	part, err := decodePart([]byte(data))
+	require.NoError(t, err)
+	tool := part.(*ToolPart)
+	assert.Equal(t, ToolError, tool.State)
+	assert.Equal(t, "exit 1", tool.Error)
+
+	encoded, err := json.Marshal(tool)
+	require.NoError(t, err)
+	assert.JSONEq(t, data, string(encoded))
+}
+
+func TestToolUpdates(t *testing.T) {
+	oc, events, _ := pushServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := oc.ToolUpdates(ctx, "ses_1")
+
+	tool := func(session, status string) string {
+		return fmt.Sprintf(`{"type":"message.part.updated","properties":{"part":{"id":"prt_1","sessionID":"%s","type":"tool","tool":"bash","state":{"status":"%s"}}}}`, session, status)
+	}
+	events <- tool("ses_1", "pending")
+	events <- tool("ses_2", "running")
+	events <- tool("ses_1", "running")
+	events <- tool("ses_1", "running")
+	events <- tool("ses_1", "completed")
+
+	var transitions []string
+	for range 3 {
+		select {
+		case update := <-updates:
+			transitions = append(transitions, fmt.Sprintf("%s->%s", update.Previous, update.Part.State))
+		case <-time.After(time.Second):
+			t.Fatal("no update received")
+		}
+	}
+	assert.Equal(t, []string{"->pending", "pending->running", "running->completed"}, transitions)
+}
@@ -0,0 +1,34 @@
+package opencode
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer creates spans for Start, WaitForReady, SendMessage and every
+// request to the server. The opencodeotel package implements it with
+// OpenTelemetry.
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+	// Inject adds the trace context of ctx to the headers of a request.
+	Inject(ctx context.Context, header http.Header)
+}
+
+type Span interface {
+	// SetAttribute records a string, int, int64, float64 or bool value.
+	SetAttribute(key string, value any)
+	// End ends the span, marking it failed if err is not nil.
+	End(err error)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+func (noopSpan) End(error)                {}
+
+func (oc *OpenCode) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if oc.config.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return oc.config.Tracer.StartSpan(ctx, name)
+}
@@ -0,0 +1,148 @@
+package opencode
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Transcript assembles the messages and parts of every session from events.
+// Pass Apply to StreamEvents or call it for each subscribed event. Messages
+// and parts are ordered by ID, which opencode assigns in creation order, so
+// events may arrive out of order; stale updates that would undo progress are
+// ignored.
+type Transcript struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]*transcriptMessage
+}
+
+type transcriptMessage struct {
+	info  Message
+	parts map[string]Part
+}
+
+func NewTranscript() *Transcript {
+	return &Transcript{sessions: make(map[string]map[string]*transcriptMessage)}
+}
+
+func (t *Transcript) Apply(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch e := event.(type) {
+	case *MessageUpdatedEvent:
+		m := t.message(e.Info.SessionID, e.Info.ID)
+		if m.info.Time.Completed > 0 && e.Info.Time.Completed == 0 {
+			return
+		}
+		m.info = e.Info
+	case *MessagePartUpdatedEvent:
+		if e.Part == nil {
+			return
+		}
+		m := t.message(e.Part.PartSessionID(), e.Part.PartMessageID())
+		if part, ok := mergePart(m.parts[e.Part.PartID()], e.Part, e.Delta); ok {
+			m.parts[e.Part.PartID()] = part
+		}
+	case *MessageRemovedEvent:
+		delete(t.sessions[e.SessionID], e.MessageID)
+	case *MessagePartRemovedEvent:
+		if m := t.sessions[e.SessionID][e.MessageID]; m != nil {
+			delete(m.parts, e.PartID)
+		}
+	case *SessionDeletedEvent:
+		delete(t.sessions, e.Info.ID)
+	}
+}
+
+func (t *Transcript) message(sessionID, messageID string) *transcriptMessage {
+	messages := t.sessions[sessionID]
+	if messages == nil {
+		messages = make(map[string]*transcriptMessage)
+		t.sessions[sessionID] = messages
+	}
+	m := messages[messageID]
+	if m == nil {
+		m = &transcriptMessage{info: Message{ID: messageID, SessionID: sessionID}, parts: make(map[string]Part)}
+		messages[messageID] = m
+	}
+	return m
+}
+
+// mergePart returns the part to keep after an update. Updates carrying only a
+// delta are appended, and updates older than the stored part are dropped.
+func mergePart(prev, next Part, delta string) (Part, bool) {
+	switch n := next.(type) {
+	case *TextPart:
+		if p, ok := prev.(*TextPart); ok {
+			text, ok := mergeText(p.Text, n.Text, delta)
+			merged := *n
+			merged.Text = text
+			return &merged, ok
+		}
+	case *ReasoningPart:
+		if p, ok := prev.(*ReasoningPart); ok {
+			text, ok := mergeText(p.Text, n.Text, delta)
+			merged := *n
+			merged.Text = text
+			return &merged, ok
+		}
+	case *ToolPart:
+		if p, ok := prev.(*ToolPart); ok && toolProgress(n.State) < toolProgress(p.State) {
+			return nil, false
+		}
+	}
+	return next, true
+}
+
+// mergeText returns the text after an update, or false if the update is
+// older than what is stored.
+func mergeText(prev, next, delta string) (string, bool) {
+	if next == "" && delta != "" {
+		return prev + delta, true
+	}
+	if len(next) < len(prev) && strings.HasPrefix(prev, next) {
+		return prev, false
+	}
+	return next, true
+}
+
+func toolProgress(status ToolStatus) int {
+	switch status {
+	case ToolRunning:
+		return 1
+	case ToolCompleted, ToolError:
+		return 2
+	}
+	return 0
+}
+
+// Sessions returns the IDs of the sessions seen so far, sorted.
+func (t *Transcript) Sessions() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := make([]string, 0, len(t.sessions))
+	for id := range t.sessions {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Messages returns a snapshot of a session's messages with their parts,
+// ordered by ID. Messages whose info has not arrived yet only have ID and
+// SessionID set.
+func (t *Transcript) Messages(sessionID string) []MessageWithParts {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := make([]MessageWithParts, 0, len(t.sessions[sessionID]))
+	for _, m := range t.sessions[sessionID] {
+		parts := make([]Part, 0, len(m.parts))
+		for _, part := range m.parts {
+			parts = append(parts, part)
+		}
+		slices.SortFunc(parts, func(a, b Part) int { return strings.Compare(a.PartID(), b.PartID()) })
+		messages = append(messages, MessageWithParts{Info: m.info, Parts: parts})
+	}
+	slices.SortFunc(messages, func(a, b MessageWithParts) int { return strings.Compare(a.Info.ID, b.Info.ID) })
+	return messages
+}
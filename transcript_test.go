@@ -0,0 +1,48 @@
+package opencode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	tr := NewTranscript()
+	for _, data := range []string{
+		// The part arrives before its message
+		`{"type":"message.part.updated","properties":{"part":{"id":"prt_2","sessionID":"ses_1","messageID":"msg_2","type":"text","text":"Hel"},"delta":"Hel"}}`,
+		`{"type":"message.updated","properties":{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant","time":{"created":2}}}}`,
+		`{"type":"message.updated","properties":{"info":{"id":"msg_1","sessionID":"ses_1","role":"user","time":{"created":1}}}}`,
+		`{"type":"message.part.updated","properties":{"part":{"id":"prt_2","sessionID":"ses_1","messageID":"msg_2","type":"text","text":""},"delta":"lo"}}`,
+		`{"type":"message.part.updated","properties":{"part":{"id":"prt_3","sessionID":"ses_1","messageID":"msg_2","type":"tool","tool":"bash","state":{"status":"completed","output":"ok"}}}}`,
+		// Stale updates
+		`{"type":"message.part.updated","properties":{"part":{"id":"prt_3","sessionID":"ses_1","messageID":"msg_2","type":"tool","tool":"bash","state":{"status":"running"}}}}`,
+		`{"type":"message.part.updated","properties":{"part":{"id":"prt_2","sessionID":"ses_1","messageID":"msg_2","type":"text","text":"He"},"delta":"e"}}`,
+		`{"type":"message.updated","properties":{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant","time":{"created":2,"completed":3}}}}`,
+		`{"type":"message.updated","properties":{"info":{"id":"msg_2","sessionID":"ses_1","role":"assistant","time":{"created":2}}}}`,
+		`{"type":"message.part.updated","properties":{"part":{"id":"prt_9","sessionID":"ses_2","messageID":"msg_9","type":"text","text":"x"}}}`,
+	} {
+		event, err := ParseEvent([]byte(data))
+		require.NoError(t, err)
+		tr.Apply(event)
+	}
+
+	assert.Equal(t, []string{"ses_1", "ses_2"}, tr.Sessions())
+	messages := tr.Messages("ses_1")
+	require.Len(t, messages, 2)
+	assert.Equal(t, "msg_1", messages[0].Info.ID)
+	answer := messages[1]
+	assert.Equal(t, int64(3), answer.Info.Time.Completed)
+	assert.Equal(t, "Hello", answer.Text())
+	require.Len(t, answer.Parts, 2)
+	assert.Equal(t, ToolCompleted, answer.Parts[1].(*ToolPart).State)
+
+	event, err := ParseEvent([]byte(`{"type":"message.part.removed","properties":{"sessionID":"ses_1","messageID":"msg_2","partID":"prt_3"}}`))
+	require.NoError(t, err)
+	tr.Apply(event)
+	assert.Len(t, tr.Messages("ses_1")[1].Parts, 1)
+
+	tr.Apply(&SessionDeletedEvent{Info: Session{ID: "ses_2"}})
+	assert.Equal(t, []string{"ses_1"}, tr.Sessions())
+}
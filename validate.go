@@ -0,0 +1,293 @@
+package opencode
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/ai-shift/opencode/opencodeconfig"
+)
+
+// Diagnostic is one problem found by ValidateConfig.
+type Diagnostic struct {
+	File string
+	// Line is 1-based, zero when the problem has no single location.
+	Line int
+	// Field is the dotted config key, e.g. "agent.review.model".
+	Field   string
+	Message string
+	// Warning marks problems opencode tolerates, such as unknown keys.
+	Warning bool
+}
+
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	sb.WriteString(d.File)
+	if d.Line > 0 {
+		fmt.Fprintf(&sb, ":%d", d.Line)
+	}
+	if d.Field != "" {
+		sb.WriteString(": " + d.Field)
+	}
+	sb.WriteString(": " + d.Message)
+	return sb.String()
+}
+
+// ConfigError is returned by ValidateConfig and Start when the config has
+// errors. Diagnostics also include the warnings.
+type ConfigError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ConfigError) Error() string {
+	var msgs []string
+	for _, d := range e.Diagnostics {
+		if !d.Warning {
+			msgs = append(msgs, d.String())
+		}
+	}
+	return "invalid opencode config: " + strings.Join(msgs, "; ")
+}
+
+// knownConfigKeys are the top-level keys of opencode's config schema.
+var knownConfigKeys = []string{
+	"$schema", "agent", "autoshare", "autoupdate", "command", "disabled_providers",
+	"enabled_providers", "enterprise", "experimental", "formatter", "instructions",
+	"keybinds", "layout", "lsp", "mcp", "mode", "model", "permission", "plugin",
+	"provider", "server", "share", "small_model", "snapshot", "theme", "tools",
+	"tui", "username", "watcher",
+}
+
+var fileRefPattern = regexp.MustCompile(`\{file:([^}]+)\}`)
+
+// ValidateConfig renders config.json the way Start would, without writing it,
+// and checks its syntax, top-level keys, model IDs and referenced files. It
+// returns a *ConfigError if there are errors and logs warnings.
+func (oc *OpenCode) ValidateConfig() error {
+	name, data, err := oc.renderedConfigJSON()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+
+	diags := oc.validateConfigJSON(name, data)
+	var hasErrors bool
+	for _, d := range diags {
+		if d.Warning {
+			oc.log.Warn("OpenCode config warning", "diagnostic", d.String())
+		} else {
+			hasErrors = true
+		}
+	}
+	if hasErrors {
+		return &ConfigError{Diagnostics: diags}
+	}
+	return nil
+}
+
+func (oc *OpenCode) renderedConfigJSON() (string, []byte, error) {
+	if oc.config.OpenCodeConfig != nil {
+		data, err := json.Marshal(oc.config.OpenCodeConfig)
+		return "config.json", data, err
+	}
+	if oc.config.ConfigFS != nil {
+		content, err := fs.ReadFile(oc.config.ConfigFS, "config.json")
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, nil
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read file config.json: %w", err)
+		}
+		data, err := oc.renderConfigFile("config.json", content)
+		return "config.json", data, err
+	}
+	if oc.config.ConfigDir != "" {
+		path := filepath.Join(oc.config.ConfigDir, "config.json")
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil, nil
+		}
+		return path, data, err
+	}
+	return "", nil, nil
+}
+
+func (oc *OpenCode) validateConfigJSON(name string, data []byte) []Diagnostic {
+	data = stripJSONC(data)
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		d := Diagnostic{File: name, Message: err.Error()}
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			d.Line = 1 + bytes.Count(data[:syntaxErr.Offset], []byte("\n"))
+		}
+		return []Diagnostic{d}
+	}
+
+	var diags []Diagnostic
+	lineOf := func(key string) int {
+		i := bytes.Index(data, []byte(`"`+key+`"`))
+		if i < 0 {
+			return 0
+		}
+		return 1 + bytes.Count(data[:i], []byte("\n"))
+	}
+	for _, key := range slices.Sorted(maps.Keys(raw)) {
+		if !slices.Contains(knownConfigKeys, key) {
+			diags = append(diags, Diagnostic{File: name, Line: lineOf(key), Field: key, Message: "unknown key", Warning: true})
+		}
+	}
+
+	var cfg opencodeconfig.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		diags = append(diags, Diagnostic{File: name, Message: fmt.Sprintf("could not check values: %v", err), Warning: true})
+	} else {
+		diags = append(diags, valueDiagnostics(name, &cfg, lineOf)...)
+	}
+
+	for _, match := range fileRefPattern.FindAllSubmatchIndex(data, -1) {
+		ref := string(data[match[2]:match[3]])
+		if !oc.configFileExists(ref) {
+			line := 1 + bytes.Count(data[:match[0]], []byte("\n"))
+			diags = append(diags, Diagnostic{File: name, Line: line, Message: fmt.Sprintf("referenced file %s does not exist", ref)})
+		}
+	}
+	for _, instruction := range cfg.Instructions {
+		if strings.ContainsAny(instruction, "*?[") {
+			continue
+		}
+		if !oc.configFileExists(instruction) && !oc.workFileExists(instruction) {
+			diags = append(diags, Diagnostic{File: name, Line: lineOf("instructions"), Field: "instructions", Message: fmt.Sprintf("instruction file %s does not exist", instruction)})
+		}
+	}
+	return diags
+}
+
+// valueDiagnostics runs the typed config checks for model IDs, agent modes,
+// MCP servers and permissions.
+func valueDiagnostics(name string, cfg *opencodeconfig.Config, lineOf func(string) int) []Diagnostic {
+	return errorDiagnostics(name, cfg.Validate(), lineOf)
+}
+
+// errorDiagnostics turns err, which may join several errors, into one
+// diagnostic per error.
+func errorDiagnostics(name string, err error, lineOf func(string) int) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	var diags []Diagnostic
+	for _, err := range errs {
+		var fieldErr *opencodeconfig.FieldError
+		if !errors.As(err, &fieldErr) {
+			diags = append(diags, Diagnostic{File: name, Message: err.Error()})
+			continue
+		}
+		key := fieldErr.Field[strings.LastIndexByte(fieldErr.Field, '.')+1:]
+		diags = append(diags, Diagnostic{File: name, Line: lineOf(key), Field: fieldErr.Field, Message: fieldErr.Message})
+	}
+	return diags
+}
+
+// configFileExists resolves paths the way opencode resolves {file:...}
+// references: relative to the config directory, with ~ for the home directory.
+func (oc *OpenCode) configFileExists(ref string) bool {
+	if rest, ok := strings.CutPrefix(ref, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		ref = filepath.Join(home, rest)
+	}
+	if filepath.IsAbs(ref) {
+		_, err := os.Stat(ref)
+		return err == nil
+	}
+	if oc.config.ConfigFS != nil {
+		if _, err := fs.Stat(oc.config.ConfigFS, filepath.ToSlash(filepath.Clean(ref))); err == nil {
+			return true
+		}
+	}
+	if oc.config.ConfigDir != "" {
+		if _, err := os.Stat(filepath.Join(oc.config.ConfigDir, ref)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (oc *OpenCode) workFileExists(path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(oc.config.CWD, path)
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// stripJSONC blanks out comments and trailing commas so JSONC parses as JSON.
+// Offsets are preserved, so syntax errors still point at the right line.
+func stripJSONC(data []byte) []byte {
+	out := bytes.Clone(data)
+	scanJSONOutsideStrings(out, func(i int) int {
+		switch {
+		case bytes.HasPrefix(out[i:], []byte("//")):
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+			return i
+		case bytes.HasPrefix(out[i:], []byte("/*")):
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			end = min(len(out), i+2+end+2)
+			if end < i+4 {
+				end = len(out)
+			}
+			for ; i < end; i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			return i
+		}
+		return i + 1
+	})
+	scanJSONOutsideStrings(out, func(i int) int {
+		if out[i] == ',' {
+			next := bytes.TrimLeft(out[i+1:], " \t\r\n")
+			if len(next) > 0 && (next[0] == '}' || next[0] == ']') {
+				out[i] = ' '
+			}
+		}
+		return i + 1
+	})
+	return out
+}
+
+// scanJSONOutsideStrings calls visit for every byte outside string literals.
+// visit returns the index to continue from.
+func scanJSONOutsideStrings(data []byte, visit func(i int) int) {
+	for i := 0; i < len(data); {
+		if data[i] != '"' {
+			i = visit(i)
+			continue
+		}
+		for i++; i < len(data) && data[i] != '"'; i++ {
+			if data[i] == '\\' {
+				i++
+			}
+		}
+		i++
+	}
+}
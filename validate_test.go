@@ -0,0 +1,89 @@
+package opencode
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ai-shift/opencode/opencodeconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	workDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "AGENTS.md"), nil, 0644))
+
+	oc := New(Config{
+		CWD: workDir,
+		ConfigFS: fstest.MapFS{
+			"config.json": {Data: []byte(`{
+  // JSONC comments and trailing commas are fine
+  "model": "sonnet",
+  "modle": "x",
+  "instructions": ["AGENTS.md", "missing.md", "docs/*.md"],
+  "agent": {
+    "review": {"prompt": "{file:./prompts/review.txt}", "mode": "subagent",},
+    "plan": {"prompt": "{file:./prompts/plan.txt}"},
+  },
+}`)},
+			"prompts/review.txt": {Data: []byte("review")},
+		},
+	})
+
+	err := oc.ValidateConfig()
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr)
+	assert.Equal(t, []Diagnostic{
+		{File: "config.json", Line: 4, Field: "modle", Message: "unknown key", Warning: true},
+		{File: "config.json", Line: 3, Field: "model", Message: `"sonnet" must be provider/model`},
+		{File: "config.json", Line: 8, Message: "referenced file ./prompts/plan.txt does not exist"},
+		{File: "config.json", Line: 5, Field: "instructions", Message: "instruction file missing.md does not exist"},
+	}, configErr.Diagnostics)
+	assert.EqualError(t, err, `invalid opencode config: config.json:3: model: "sonnet" must be provider/model; config.json:8: referenced file ./prompts/plan.txt does not exist; config.json:5: instructions: instruction file missing.md does not exist`)
+}
+
+func TestValidateConfigSyntaxError(t *testing.T) {
+	oc := New(Config{ConfigFS: fstest.MapFS{"config.json": {Data: []byte("{\n  \"model\": \"a/b\"\n  \"theme\": \"x\"\n}")}}})
+	var configErr *ConfigError
+	require.ErrorAs(t, oc.ValidateConfig(), &configErr)
+	require.Len(t, configErr.Diagnostics, 1)
+	assert.Equal(t, 3, configErr.Diagnostics[0].Line)
+}
+
+func TestValidateConfigWarningsOnly(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"future_option": true, "permission": {"bash": "ask"}}`), 0644))
+	oc := New(Config{ConfigDir: dir})
+	assert.NoError(t, oc.ValidateConfig())
+
+	assert.NoError(t, New(Config{}).ValidateConfig())
+}
+
+func TestValidateConfigTyped(t *testing.T) {
+	oc := New(Config{OpenCodeConfig: &opencodeconfig.Config{
+		MCP: map[string]opencodeconfig.MCP{"fs": {Type: opencodeconfig.MCPLocal}},
+	}})
+	var configErr *ConfigError
+	require.ErrorAs(t, oc.ValidateConfig(), &configErr)
+	assert.Equal(t, "mcp.fs.command", configErr.Diagnostics[0].Field)
+}
+
+func TestErrorDiagnostics(t *testing.T) {
+	lineOf := func(key string) int { return len(key) }
+	diags := errorDiagnostics("opencode.json", &opencodeconfig.FieldError{Field: "agent.review.mode", Message: "bad mode"}, lineOf)
+	assert.Equal(t, []Diagnostic{{File: "opencode.json", Line: 4, Field: "agent.review.mode", Message: "bad mode"}}, diags)
+
+	diags = errorDiagnostics("opencode.json", errors.Join(errors.New("one"), errors.New("two")), lineOf)
+	assert.Len(t, diags, 2)
+	assert.Nil(t, errorDiagnostics("opencode.json", nil, lineOf))
+}
+
+func TestStripJSONC(t *testing.T) {
+	in := "{\"a\": \"// not a comment\", /* block\n comment */ \"b\": [1, 2,], // line\n}"
+	out := stripJSONC([]byte(in))
+	assert.Len(t, out, len(in))
+	assert.JSONEq(t, `{"a": "// not a comment", "b": [1, 2]}`, string(out))
+}
@@ -0,0 +1,81 @@
+package opencode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrVersionTooOld = errors.New("opencode version too old")
+
+// checkVersion runs "opencode --version" and fails if it reports a version
+// older than min.
+func checkVersion(binary, min string, env []string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, binary, "--version")
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get opencode version: %w", err)
+	}
+	version := strings.TrimSpace(string(out))
+
+	cmp, err := compareVersions(version, min)
+	if err != nil {
+		return version, err
+	}
+	if cmp < 0 {
+		return version, fmt.Errorf("%w: %s is older than the required %s", ErrVersionTooOld, version, min)
+	}
+	return version, nil
+}
+
+// compareVersions compares dotted numeric versions like "0.15.2", ignoring a
+// leading "v" and any "-" or "+" suffix.
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range max(len(pa), len(pb)) {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1, nil
+			}
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([]int, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(v), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	var parts []int
+	for _, field := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("malformed version %q", v)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
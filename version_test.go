@@ -0,0 +1,28 @@
+package opencode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"0.15.0", "0.15.0", 0},
+		{"v0.15.1", "0.15.0", 1},
+		{"0.9.9", "0.10.0", -1},
+		{"1.0", "1.0.0", 0},
+		{"1.2.0-beta.1", "1.2.0", 0},
+		{"2.0.0", "10.0.0", -1},
+	} {
+		got, err := compareVersions(tt.a, tt.b)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s vs %s", tt.a, tt.b)
+	}
+
+	_, err := compareVersions("local", "0.15.0")
+	assert.EqualError(t, err, `malformed version "local"`)
+}
@@ -0,0 +1,220 @@
+// Package worktree gives every session its own git worktree and branch, so
+// agents working on the same repository in parallel do not overwrite each
+// other's changes.
+package worktree
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ai-shift/opencode"
+)
+
+type Manager struct {
+	Client opencode.Client
+	// Repo is the path of the git repository.
+	Repo string
+	// Dir holds the worktrees. Defaults to "<repo>-worktrees" next to Repo.
+	Dir string
+	// BranchPrefix is prepended to the worktree name to name its branch.
+	// Defaults to "opencode/".
+	BranchPrefix string
+	// Base is the commit new branches start from. Defaults to HEAD.
+	Base string
+	// DeleteBranch deletes a worktree's branch along with it. By default the
+	// branch is kept, so the agent's commits can still be merged.
+	DeleteBranch bool
+	// Logger defaults to the client's logger.
+	Logger *slog.Logger
+}
+
+// Worktree is a session together with the worktree it works in.
+type Worktree struct {
+	Session *opencode.Session
+	Path    string
+	Branch  string
+}
+
+// Context scopes requests made with the returned context to the worktree,
+// which is how the server finds the session.
+func (w *Worktree) Context(ctx context.Context) context.Context {
+	return opencode.WithDirectory(ctx, w.Path)
+}
+
+func (m *Manager) logger() *slog.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	if oc, ok := m.Client.(*opencode.OpenCode); ok {
+		return oc.Logger()
+	}
+	return slog.Default()
+}
+
+func (m *Manager) dir() (string, error) {
+	dir := m.Dir
+	if dir == "" {
+		repo, err := filepath.Abs(m.Repo)
+		if err != nil {
+			return "", err
+		}
+		dir = repo + "-worktrees"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+	// git reports worktrees by their real path
+	return filepath.EvalSymlinks(dir)
+}
+
+func (m *Manager) branch(path string) string {
+	prefix := m.BranchPrefix
+	if prefix == "" {
+		prefix = "opencode/"
+	}
+	return prefix + filepath.Base(path)
+}
+
+func (m *Manager) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", m.Repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// Create adds a worktree on a new branch and creates a session working in
+// it. Send the session's messages with the worktree's Context.
+func (m *Manager) Create(ctx context.Context, create opencode.SessionCreate) (*Worktree, error) {
+	dir, err := m.dir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "session-"+strings.ToLower(rand.Text()[:10]))
+	w := &Worktree{Path: path, Branch: m.branch(path)}
+	base := m.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	if _, err := m.git(ctx, "worktree", "add", "-b", w.Branch, path, base); err != nil {
+		return nil, fmt.Errorf("failed to add worktree: %w", err)
+	}
+
+	w.Session, err = m.Client.CreateSession(w.Context(ctx), create)
+	if err != nil {
+		return nil, errors.Join(err, m.remove(context.WithoutCancel(ctx), path))
+	}
+	m.logger().Debug("Created session worktree", "session", w.Session.ID, "path", path, "branch", w.Branch)
+	return w, nil
+}
+
+// DeleteSession deletes a session created by Create and removes its
+// worktree.
+func (m *Manager) DeleteSession(ctx context.Context, w *Worktree) error {
+	if err := m.Client.DeleteSession(w.Context(ctx), w.Session.ID); err != nil && !errors.Is(err, opencode.ErrSessionNotFound) {
+		return err
+	}
+	return m.remove(ctx, w.Path)
+}
+
+func (m *Manager) remove(ctx context.Context, path string) error {
+	if _, err := m.git(ctx, "worktree", "remove", "--force", path); err != nil {
+		return fmt.Errorf("failed to remove worktree: %w", err)
+	}
+	if m.DeleteBranch {
+		if _, err := m.git(ctx, "branch", "-D", m.branch(path)); err != nil {
+			return fmt.Errorf("failed to delete worktree branch: %w", err)
+		}
+	}
+	m.logger().Debug("Removed session worktree", "path", path)
+	return nil
+}
+
+// worktrees returns the paths of the worktrees in Dir.
+func (m *Manager) worktrees(ctx context.Context) ([]string, error) {
+	dir, err := m.dir()
+	if err != nil {
+		return nil, err
+	}
+	out, err := m.git(ctx, "worktree", "list", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	var paths []string
+	for line := range strings.Lines(out) {
+		path, ok := strings.CutPrefix(strings.TrimSpace(line), "worktree ")
+		if ok && filepath.Dir(path) == dir {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// Prune removes the worktrees in Dir that no session works in anymore, e.g.
+// because the session was deleted elsewhere, and returns their paths. A
+// worktree whose session Create is still creating counts as unused.
+func (m *Manager) Prune(ctx context.Context) ([]string, error) {
+	paths, err := m.worktrees(ctx)
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	// Worktrees share the repository's project, so this lists their sessions
+	sessions, err := m.Client.ListSessions(opencode.WithDirectory(ctx, m.Repo))
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool)
+	for _, session := range sessions {
+		used[filepath.Clean(session.Directory)] = true
+	}
+
+	var pruned []string
+	var errs []error
+	for _, path := range paths {
+		if used[path] {
+			continue
+		}
+		if err := m.remove(ctx, path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruned = append(pruned, path)
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// Watch removes a session's worktree when a session.deleted event reports it
+// deleted, until ctx is done. It prunes once at the start to catch up on
+// sessions deleted while it was not watching.
+func (m *Manager) Watch(ctx context.Context) {
+	events := opencode.Subscribe[*opencode.SessionDeletedEvent](ctx, m.Client)
+	if _, err := m.Prune(ctx); err != nil {
+		m.logger().Warn("Failed to prune session worktrees", "err", err)
+	}
+	for event := range events {
+		paths, err := m.worktrees(ctx)
+		if err != nil {
+			m.logger().Warn("Failed to list session worktrees", "err", err)
+			continue
+		}
+		for _, path := range paths {
+			if path != filepath.Clean(event.Info.Directory) {
+				continue
+			}
+			if err := m.remove(ctx, path); err != nil {
+				m.logger().Warn("Failed to remove session worktree", "session", event.Info.ID, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package worktree
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-shift/opencode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	repo := filepath.Join(t.TempDir(), "repo")
+	require.NoError(t, os.MkdirAll(repo, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(repo, "README"), []byte("hello\n"), 0644))
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "README"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-qm", "init"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return repo
+}
+
+// fakeServer keeps sessions in the directory they were created in and sends
+// session.deleted events for deletions.
+func fakeServer(t *testing.T) (*opencode.OpenCode, func(id string)) {
+	var mu sync.Mutex
+	sessions := map[string]opencode.Session{}
+	events := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/event":
+			mu.Unlock()
+			defer mu.Lock()
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+			for {
+				select {
+				case <-r.Context().Done():
+					return
+				case event := <-events:
+					fmt.Fprintf(w, "data: %s\n\n", event)
+					w.(http.Flusher).Flush()
+				}
+			}
+		case r.URL.Path == "/session" && r.Method == http.MethodPost:
+			session := opencode.Session{ID: fmt.Sprintf("ses_%d", len(sessions)+1), Directory: r.URL.Query().Get("directory")}
+			sessions[session.ID] = session
+			json.NewEncoder(w).Encode(session)
+		case r.URL.Path == "/session":
+			list := []opencode.Session{}
+			for _, session := range sessions {
+				list = append(list, session)
+			}
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodDelete:
+			id := strings.TrimPrefix(r.URL.Path, "/session/")
+			session, ok := sessions[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(sessions, id)
+			info, _ := json.Marshal(session)
+			events <- fmt.Sprintf(`{"type":"session.deleted","properties":{"info":%s}}`, info)
+			w.Write([]byte("true"))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	// deleteElsewhere deletes a session without sending an event
+	deleteElsewhere := func(id string) {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(sessions, id)
+	}
+	return opencode.New(opencode.Config{Addr: strings.TrimPrefix(srv.URL, "http://")}), deleteElsewhere
+}
+
+func branches(t *testing.T, repo string) string {
+	out, err := exec.Command("git", "-C", repo, "branch", "--list", "opencode/*").Output()
+	require.NoError(t, err)
+	return string(out)
+}
+
+func TestCreateAndDeleteSession(t *testing.T) {
+	repo := gitRepo(t)
+	oc, _ := fakeServer(t)
+	m := &Manager{Client: oc, Repo: repo, DeleteBranch: true}
+	ctx := context.Background()
+
+	a, err := m.Create(ctx, opencode.SessionCreate{Title: "a"})
+	require.NoError(t, err)
+	b, err := m.Create(ctx, opencode.SessionCreate{Title: "b"})
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Path, b.Path)
+	assert.Equal(t, repo+"-worktrees", filepath.Dir(a.Path))
+	assert.Equal(t, a.Path, a.Session.Directory)
+	assert.FileExists(t, filepath.Join(a.Path, "README"))
+	assert.Contains(t, branches(t, repo), a.Branch)
+
+	require.NoError(t, m.DeleteSession(ctx, a))
+	assert.NoDirExists(t, a.Path)
+	assert.NotContains(t, branches(t, repo), a.Branch)
+	assert.DirExists(t, b.Path)
+}
+
+func TestWatchAndPrune(t *testing.T) {
+	repo := gitRepo(t)
+	oc, deleteElsewhere := fakeServer(t)
+	m := &Manager{Client: oc, Repo: repo, Dir: filepath.Join(t.TempDir(), "trees")}
+	ctx := context.Background()
+
+	a, err := m.Create(ctx, opencode.SessionCreate{})
+	require.NoError(t, err)
+	b, err := m.Create(ctx, opencode.SessionCreate{})
+	require.NoError(t, err)
+
+	deleteElsewhere(b.Session.ID)
+	pruned, err := m.Prune(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{b.Path}, pruned)
+	assert.Contains(t, branches(t, repo), b.Branch)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go m.Watch(watchCtx)
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, oc.DeleteSession(a.Context(ctx), a.Session.ID))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(a.Path)
+		return os.IsNotExist(err)
+	}, 2*time.Second, 20*time.Millisecond)
+}